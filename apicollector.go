@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -10,16 +11,19 @@ import (
 type ApiCollector interface {
 	sync.Locker
 	prometheus.Collector
-	// fetch data from api endpoint
-	Fetch() (data []interface{}, err error)
+	// fetch data from api endpoint, until ctx is done
+	Fetch(ctx context.Context) (data []interface{}, err error)
 	// save data in collector
 	SaveData(data []interface{}) error
 	SetFetchTime(time.Time)
+	LastFetchTime() time.Time
 	IsDataFresh() bool
-
-	// Check current time against minimal fetch interval. Returns true if time has elapsed longer than
-	// the minimal interval since last fetch.
-	CheckMinFetchInterval() bool
+	// set the maximal age of saved data and the minimal interval between fetches
+	SetIntervals(maxAge, minInterval time.Duration)
+	MinInterval() time.Duration
+	// set the maximal duration of a fetch
+	SetTimeout(timeout time.Duration)
+	Timeout() time.Duration
 }
 
 type ApiCollectorBase struct {
@@ -27,13 +31,7 @@ type ApiCollectorBase struct {
 	lastFetchTime time.Time
 	maxAge        time.Duration
 	minInterval   time.Duration
-}
-
-func (m *ApiCollectorBase) CheckMinFetchInterval() bool {
-	if time.Since(m.lastFetchTime) < m.minInterval {
-		return false
-	}
-	return true
+	timeout       time.Duration
 }
 
 func (m *ApiCollectorBase) IsDataFresh() bool {
@@ -43,3 +41,24 @@ func (m *ApiCollectorBase) IsDataFresh() bool {
 func (m *ApiCollectorBase) SetFetchTime(t time.Time) {
 	m.lastFetchTime = t
 }
+
+func (m *ApiCollectorBase) LastFetchTime() time.Time {
+	return m.lastFetchTime
+}
+
+func (m *ApiCollectorBase) SetIntervals(maxAge, minInterval time.Duration) {
+	m.maxAge = maxAge
+	m.minInterval = minInterval
+}
+
+func (m *ApiCollectorBase) MinInterval() time.Duration {
+	return m.minInterval
+}
+
+func (m *ApiCollectorBase) SetTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+func (m *ApiCollectorBase) Timeout() time.Duration {
+	return m.timeout
+}
@@ -1,35 +1,148 @@
 package main
 
 import (
+	"context"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type NetappCollector struct {
-	AggrCollector   *AggrCollector
-	VolumeCollector *VolumeCollector
-	scrapeFailure   prometheus.Counter
-	scrapeCounter   prometheus.Counter
+	Collectors    map[string]ApiCollector
+	filer         NetappFilerClient
+	serveStale    map[string]bool
+	limiter       fetchLimiter
+	pollers       *sync.WaitGroup
+	scrapeFailure prometheus.Counter
+	scrapeCounter prometheus.Counter
+	fetchDuration *prometheus.HistogramVec
 }
 
+var (
+	lastSuccessDesc = prometheus.NewDesc(
+		"netapp_collector_last_success_timestamp_seconds",
+		"Time of the last successful fetch of the collector.",
+		[]string{"collector"},
+		nil)
+	dataAgeDesc = prometheus.NewDesc(
+		"netapp_collector_data_age_seconds",
+		"Age of the data exported by the collector.",
+		[]string{"collector"},
+		nil)
+	staleDesc = prometheus.NewDesc(
+		"netapp_collector_stale",
+		"Data of the collector is older than its max age or has not been fetched yet (1: stale; 0: fresh).",
+		[]string{"collector"},
+		nil)
+)
+
+// collectorFactories maps the collector names, which can be listed per filer in netapp_filers.yaml, to
+// their constructors.
+var collectorFactories = map[string]func(filer NetappFilerClient) ApiCollector{
+	"volume":      func(f NetappFilerClient) ApiCollector { return &VolumeCollector{Filer: f} },
+	"aggregate":   func(f NetappFilerClient) ApiCollector { return &AggrCollector{Filer: f} },
+	"snapshot":    func(f NetappFilerClient) ApiCollector { return &SnapshotCollector{Filer: f} },
+	"quota":       func(f NetappFilerClient) ApiCollector { return &QuotaCollector{Filer: f} },
+	"lun":         func(f NetappFilerClient) ApiCollector { return &LunCollector{Filer: f} },
+	"snapmirror":  func(f NetappFilerClient) ApiCollector { return &SnapMirrorCollector{Filer: f} },
+	"node":        func(f NetappFilerClient) ApiCollector { return &NodeCollector{Filer: f} },
+	"disk":        func(f NetappFilerClient) ApiCollector { return &DiskCollector{Filer: f} },
+	"volume_perf": func(f NetappFilerClient) ApiCollector { return &VolumePerfCollector{Filer: f} },
+	"network":     func(f NetappFilerClient) ApiCollector { return &NetworkCollector{Filer: f} },
+	"vserver":     func(f NetappFilerClient) ApiCollector { return &VserverCollector{Filer: f} },
+}
+
+// defaultCollectors are enabled for filers without a collectors list, with either backend. The other
+// collectors put more load on the filers and have to be listed explicitly.
+var defaultCollectors = []string{"volume", "aggregate"}
+
+// restCollectors are the collectors that are implemented for the REST backend.
+var restCollectors = []string{"volume", "aggregate"}
+
+func isRESTCollector(name string) bool {
+	for _, n := range restCollectors {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultMaxAge               = 5 * time.Minute
+	defaultMinInterval          = 2 * time.Minute
+	defaultTimeout              = 5 * time.Minute
+	defaultMaxConcurrentFetches = 2
+)
+
 func NewNetappCollector(filer NetappFilerClient) NetappCollector {
+	configs := filer.Collectors
+	if len(configs) == 0 {
+		for _, name := range defaultCollectors {
+			configs = append(configs, CollectorConfig{Name: name})
+		}
+	}
+
+	collectors := make(map[string]ApiCollector)
+	serveStale := make(map[string]bool)
+	for _, cc := range configs {
+		newCollector, ok := collectorFactories[cc.Name]
+		if !ok {
+			logger.Warnf("%s: unknown collector %q", filer.Name, cc.Name)
+			continue
+		}
+		// intervals of the collector override those of the filer, which override the defaults
+		maxAge, minInterval := cc.MaxAge, cc.MinInterval
+		if maxAge == 0 {
+			maxAge = filer.MaxAge
+		}
+		if maxAge == 0 {
+			maxAge = defaultMaxAge
+		}
+		if minInterval == 0 {
+			minInterval = filer.MinInterval
+		}
+		if minInterval == 0 {
+			minInterval = defaultMinInterval
+		}
+		if minInterval > maxAge {
+			logger.Warnf("%s: min_interval (%s) of collector %s exceeds max_age (%s)", filer.Name, minInterval, cc.Name, maxAge)
+		}
+
+		timeout := cc.Timeout
+		if timeout == 0 {
+			timeout = filer.Timeout
+		}
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+
+		stale := cc.ServeStale
+		if stale == nil {
+			stale = filer.ServeStale
+		}
+		serveStale[cc.Name] = stale != nil && *stale
+
+		c := newCollector(filer)
+		c.SetIntervals(maxAge, minInterval)
+		c.SetTimeout(timeout)
+		collectors[cc.Name] = c
+	}
+
+	maxConcurrentFetches := filer.MaxConcurrentFetches
+	if maxConcurrentFetches == 0 {
+		maxConcurrentFetches = defaultMaxConcurrentFetches
+	}
+
 	return NetappCollector{
-		AggrCollector: &AggrCollector{
-			Filer: filer,
-			ApiCollectorBase: ApiCollectorBase{
-				maxAge:      5 * time.Minute,
-				minInterval: 2 * time.Minute,
-			},
-		},
-		VolumeCollector: &VolumeCollector{
-			Filer: filer,
-			ApiCollectorBase: ApiCollectorBase{
-				maxAge:      5 * time.Minute,
-				minInterval: 2 * time.Minute,
-			},
-		},
+		Collectors: collectors,
+		filer:      filer,
+		serveStale: serveStale,
+		limiter:    newFetchLimiter(maxConcurrentFetches),
+		pollers:    &sync.WaitGroup{},
 		scrapeFailure: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: "netapp",
 			Subsystem: "filer",
@@ -42,6 +155,13 @@ func NewNetappCollector(filer NetappFilerClient) NetappCollector {
 			Name:      "scrape_counter",
 			Help:      "The number of scrapes.",
 		}),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "netapp",
+			Subsystem: "collector",
+			Name:      "fetch_duration_seconds",
+			Help:      "Duration of fetching data from the filer, including failed fetches.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"collector"}),
 	}
 }
 
@@ -49,77 +169,167 @@ func (n NetappCollector) Describe(ch chan<- *prometheus.Desc) {
 	logger.Debug("calling Describe()")
 	ch <- n.scrapeFailure.Desc()
 	ch <- n.scrapeCounter.Desc()
-	n.VolumeCollector.Describe(ch)
-	n.AggrCollector.Describe(ch)
+	ch <- lastSuccessDesc
+	ch <- dataAgeDesc
+	ch <- staleDesc
+	n.fetchDuration.Describe(ch)
+	for _, c := range n.Collectors {
+		c.Describe(ch)
+	}
 }
 
 func (n NetappCollector) Collect(ch chan<- prometheus.Metric) {
 	logger.Debug("calling Collect()")
 
-	wg := &sync.WaitGroup{}
-	wg.Add(2)
-	go n.fetchAndCollect(n.VolumeCollector, ch, wg)
-	go n.fetchAndCollect(n.AggrCollector, ch, wg)
-	wg.Wait()
+	// Data is fetched in the background by poll(), so only saved data is exported here. Stale data is
+	// dropped, unless serveStale is set for the collector.
+	for name, c := range n.Collectors {
+		c.Lock()
+		fresh := c.IsDataFresh()
+		if fresh || n.serveStale[name] {
+			c.Collect(ch)
+		}
+		lastFetchTime := c.LastFetchTime()
+		c.Unlock()
+
+		// Collectors without data yet are stale, so that failing fetches are visible from the start.
+		if lastFetchTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(staleDesc, prometheus.GaugeValue, 1, name)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(lastSuccessDesc, prometheus.GaugeValue,
+			float64(lastFetchTime.UnixNano())/1e9, name)
+		ch <- prometheus.MustNewConstMetric(dataAgeDesc, prometheus.GaugeValue,
+			time.Since(lastFetchTime).Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(staleDesc, prometheus.GaugeValue, boolToFloat(!fresh), name)
+	}
 
 	ch <- n.scrapeFailure
 	ch <- n.scrapeCounter
+	n.fetchDuration.Collect(ch)
 }
 
-func (n NetappCollector) fetchAndCollect(m ApiCollector, ch chan<- prometheus.Metric, wg *sync.WaitGroup) {
-	defer wg.Done()
+// Start polls all collectors in the background, each on its own timer, until ctx is done.
+func (n NetappCollector) Start(ctx context.Context) {
+	for name, c := range n.Collectors {
+		n.pollers.Add(1)
+		go func(name string, c ApiCollector) {
+			defer n.pollers.Done()
+			n.poll(ctx, name, c)
+		}(name, c)
+	}
+}
 
-	// fetch() makes expensive http request to netapp's ONTAP system. The success channel is closed when
-	// request is returned successfully, otherwise fail channel is closed.
-	success := make(chan bool)
-	fail := make(chan bool)
-	go n.fetch(m, success, fail)
+// Wait waits for the pollers started by Start to return after their context is done.
+func (n NetappCollector) Wait() {
+	n.pollers.Wait()
+}
 
-	// Since fetch() is called in go routine, metrics can be exported right away, when data is recent enough.
-	m.Lock()
-	if m.IsDataFresh() {
-		m.Collect(ch)
-		m.Unlock()
-		return
-	}
-
-	// Data is not recent, and we have to wait for fetch() to finish.
-	m.Unlock()
-	select {
-	case <-success:
-		m.Lock()
-		m.Collect(ch)
-		m.Unlock()
-	case <-fail:
-	}
-	return
+// Probe fetches all collectors once and waits for them to finish, or until ctx is done. It returns whether
+// all fetches were successful.
+func (n NetappCollector) Probe(ctx context.Context) bool {
+	var wg sync.WaitGroup
+	var failed int32
+	for name, c := range n.Collectors {
+		wg.Add(1)
+		go func(name string, c ApiCollector) {
+			defer wg.Done()
+			if !n.fetch(ctx, name, c) {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(name, c)
+	}
+	wg.Wait()
+	return failed == 0
 }
 
-func (n NetappCollector) fetch(m ApiCollector, success, fail chan<- bool) {
-	if !m.CheckMinFetchInterval() {
-		logger.Warn("Minimal fetch interval violated")
-		close(fail)
-		// return to skip fetching.
-		return
+// poll fetches the data of m every min interval. The first fetch is delayed randomly and every interval
+// varies by pollJitter, so that the collectors of all filers do not fetch at the same time.
+func (n NetappCollector) poll(ctx context.Context, name string, m ApiCollector) {
+	delay := initialPollDelay(m.MinInterval())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		n.fetch(ctx, name, m)
+		delay = jitter(m.MinInterval())
 	}
+}
+
+const (
+	// pollJitter is the fraction by which the poll intervals vary.
+	pollJitter = 0.1
+	// maxInitialPollDelay limits the delay of the first fetch, so that data is available soon after start.
+	maxInitialPollDelay = 30 * time.Second
+)
 
-	data, err := m.Fetch()
+// initialPollDelay returns a random delay of the first fetch of a collector polled every interval.
+func initialPollDelay(interval time.Duration) time.Duration {
+	if interval > maxInitialPollDelay {
+		interval = maxInitialPollDelay
+	}
+	if interval <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(interval)))
+}
+
+// jitter returns d varied randomly by up to pollJitter in either direction.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((2*rand.Float64()-1)*pollJitter*float64(d))
+}
+
+// fetch() makes expensive http request to netapp's ONTAP system, and saves the data in the collector when
+// the request returned successfully. It returns whether the data has been saved.
+//
+// The fetches of a collector are sequential requests, so limiting the concurrent fetches per filer and
+// across all filers limits the concurrent requests to the filers.
+func (n NetappCollector) fetch(ctx context.Context, name string, m ApiCollector) bool {
+	defer n.scrapeCounter.Inc()
+
+	// the filer slot is taken first, so that no global slot is held while waiting for the filer
+	if err := n.limiter.acquire(ctx); err != nil {
+		return false
+	}
+	defer n.limiter.release()
+	if err := globalFetchLimiter.acquire(ctx); err != nil {
+		return false
+	}
+	defer globalFetchLimiter.release()
+
+	// the timeout starts after waiting for the limiters
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout())
+	defer cancel()
+
+	if err := n.filer.checkAuth(); err != nil {
+		logger.Error(err)
+		n.scrapeFailure.Inc()
+		return false
+	}
+	client := n.filer.NetappClient()
+
+	start := time.Now()
+	data, err := m.Fetch(ctx)
+	n.fetchDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
 	if err != nil {
 		logger.Error(err)
 		n.scrapeFailure.Inc()
-		close(fail)
-	} else {
-		m.Lock()
-		err = m.SaveData(data)
-		if err != nil {
-			m.Unlock()
-			logger.Error(err)
-			close(fail)
+		if isAuthError(err) {
+			n.filer.authFailed(client)
 		}
-		// set time after fetching. The fetch interval does not include the fetching time.
-		m.SetFetchTime(time.Now())
-		m.Unlock()
-		close(success)
+		return false
+	}
+	n.filer.authSucceeded()
+
+	m.Lock()
+	defer m.Unlock()
+	if err := m.SaveData(data); err != nil {
+		logger.Error(err)
+		return false
 	}
-	n.scrapeCounter.Inc()
+	// set time after fetching. The fetch interval does not include the fetching time.
+	m.SetFetchTime(time.Now())
+	return true
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFilerConfig returns the config of the fake filer s with the given collectors.
+func fakeFilerConfig(s *fakezapi.Server, collectors ...string) NetappFiler {
+	f := NetappFiler{Name: "fake", Host: s.Host(), Username: "user", Password: "secret"}
+	for _, c := range collectors {
+		f.Collectors = append(f.Collectors, CollectorConfig{Name: c})
+	}
+	return f
+}
+
+// newFakeFiler returns a client of the fake filer s with the given collectors.
+func newFakeFiler(t *testing.T, s *fakezapi.Server, collectors ...string) NetappFilerClient {
+	filer, err := NewNetappClient(fakeFilerConfig(s, collectors...), ClientOptions{})
+	assert.NoError(t, err)
+	return filer
+}
+
+func TestNewNetappCollector(t *testing.T) {
+	c := NewNetappCollector(NetappFilerClient{NetappFiler: NetappFiler{Name: "filer", Host: "localhost"}})
+	assert.Len(t, c.Collectors, 2)
+	assert.IsType(t, &VolumeCollector{}, c.Collectors["volume"])
+	assert.IsType(t, &AggrCollector{}, c.Collectors["aggregate"])
+
+	c = NewNetappCollector(NetappFilerClient{NetappFiler: NetappFiler{Name: "filer", Host: "localhost", Collectors: []CollectorConfig{{Name: "aggregate"}, {Name: "unknown"}}}})
+	assert.Len(t, c.Collectors, 1)
+	assert.IsType(t, &AggrCollector{}, c.Collectors["aggregate"])
+
+	c = NewNetappCollector(NetappFilerClient{NetappFiler: NetappFiler{
+		Name:        "filer",
+		Host:        "localhost",
+		MinInterval: 30 * time.Second,
+		Timeout:     time.Minute,
+		Collectors:  []CollectorConfig{{Name: "volume", MaxAge: time.Minute}, {Name: "aggregate", Timeout: 10 * time.Second}},
+	}})
+	v := c.Collectors["volume"].(*VolumeCollector)
+	assert.Equal(t, time.Minute, v.maxAge)
+	assert.Equal(t, 30*time.Second, v.minInterval)
+	assert.Equal(t, time.Minute, v.Timeout())
+	assert.Equal(t, 10*time.Second, c.Collectors["aggregate"].Timeout())
+}
+
+func TestNetappCollectorProbe(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+
+	c := NewNetappCollector(newFakeFiler(t, s, "volume", "aggregate"))
+	assert.True(t, c.Probe(context.Background()))
+	assert.True(t, c.Collectors["volume"].IsDataFresh())
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeFailure))
+
+	// a failing collector does not affect the others
+	s.SetHTTPStatus("aggr-get-iter", http.StatusInternalServerError)
+	c = NewNetappCollector(newFakeFiler(t, s, "volume", "aggregate"))
+	assert.False(t, c.Probe(context.Background()))
+	assert.True(t, c.Collectors["volume"].IsDataFresh())
+	assert.False(t, c.Collectors["aggregate"].IsDataFresh())
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeFailure))
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.scrapeCounter))
+}
+
+func TestNetappCollectorFetchTimeout(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+	s.SetLatency(100 * time.Millisecond)
+
+	filer := newFakeFiler(t, s, "volume")
+	filer.Collectors[0].Timeout = 50 * time.Millisecond
+	c := NewNetappCollector(filer)
+	assert.False(t, c.Probe(context.Background()))
+	// paging stops after the timeout
+	assert.Equal(t, 1, s.Requests("volume-get-iter"))
+}
+
+func TestNetappCollectorConcurrency(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+	s.SetLatency(20 * time.Millisecond)
+
+	filer := newFakeFiler(t, s, "volume", "aggregate")
+	filer.MaxConcurrentFetches = 1
+	c := NewNetappCollector(filer)
+	assert.True(t, c.Probe(context.Background()))
+	assert.Equal(t, 1, s.MaxInFlight())
+}
+
+func TestNetappCollectorCollect(t *testing.T) {
+	c := NewNetappCollector(NetappFilerClient{NetappFiler: NetappFiler{
+		Name:       "filer",
+		Host:       "localhost",
+		MaxAge:     time.Minute,
+		Collectors: []CollectorConfig{{Name: "volume"}, {Name: "aggregate"}, {Name: "snapshot"}},
+	}})
+	fresh := time.Now().Truncate(time.Second)
+	stale := fresh.Add(-time.Hour)
+	c.Collectors["volume"].SetFetchTime(fresh)
+	c.Collectors["aggregate"].SetFetchTime(stale)
+
+	// the snapshot collector has never fetched data
+	expected := fmt.Sprintf(`
+# HELP netapp_collector_last_success_timestamp_seconds Time of the last successful fetch of the collector.
+# TYPE netapp_collector_last_success_timestamp_seconds gauge
+netapp_collector_last_success_timestamp_seconds{collector="aggregate"} %d
+netapp_collector_last_success_timestamp_seconds{collector="volume"} %d
+# HELP netapp_collector_stale Data of the collector is older than its max age or has not been fetched yet (1: stale; 0: fresh).
+# TYPE netapp_collector_stale gauge
+netapp_collector_stale{collector="aggregate"} 1
+netapp_collector_stale{collector="snapshot"} 1
+netapp_collector_stale{collector="volume"} 0
+`, stale.Unix(), fresh.Unix())
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"netapp_collector_last_success_timestamp_seconds", "netapp_collector_stale"))
+}
+
+// exportedCollectors returns the collectors whose data c exports, by the prefix of the metric names.
+func exportedCollectors(t *testing.T, c NetappCollector) []string {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	var res []string
+	for _, prefix := range []string{"netapp_aggregate_", "netapp_volume_"} {
+		for _, f := range families {
+			if strings.HasPrefix(f.GetName(), prefix) {
+				res = append(res, strings.TrimSuffix(strings.TrimPrefix(prefix, "netapp_"), "_"))
+				break
+			}
+		}
+	}
+	return res
+}
+
+func TestNetappCollectorServeStale(t *testing.T) {
+	serveStale := true
+	newCollector := func(filerServeStale *bool) NetappCollector {
+		c := NewNetappCollector(NetappFilerClient{NetappFiler: NetappFiler{
+			Name:       "filer",
+			Host:       "localhost",
+			MaxAge:     time.Minute,
+			ServeStale: filerServeStale,
+			Collectors: []CollectorConfig{{Name: "aggregate"}, {Name: "volume", ServeStale: &serveStale}},
+		}})
+		c.Collectors["aggregate"].SaveData([]interface{}{&NetappAggregate{Name: "aggr1", OwnerName: "node1"}})
+		c.Collectors["volume"].SaveData([]interface{}{&NetappVolume{Vserver: "vs1", Volume: "vol1"}})
+		return c
+	}
+
+	c := newCollector(nil)
+	c.Collectors["aggregate"].SetFetchTime(time.Now())
+	c.Collectors["volume"].SetFetchTime(time.Now())
+	assert.Equal(t, []string{"aggregate", "volume"}, exportedCollectors(t, c))
+
+	// stale data is dropped, unless serve_stale is set for the collector or the filer
+	c.Collectors["aggregate"].SetFetchTime(time.Now().Add(-time.Hour))
+	c.Collectors["volume"].SetFetchTime(time.Now().Add(-time.Hour))
+	assert.Equal(t, []string{"volume"}, exportedCollectors(t, c))
+
+	c = newCollector(&serveStale)
+	c.Collectors["aggregate"].SetFetchTime(time.Now().Add(-time.Hour))
+	c.Collectors["volume"].SetFetchTime(time.Now().Add(-time.Hour))
+	assert.Equal(t, []string{"aggregate", "volume"}, exportedCollectors(t, c))
+}
+
+func TestPollDelays(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Minute)
+		assert.True(t, d >= 54*time.Second && d <= 66*time.Second, "jitter %s", d)
+		assert.True(t, initialPollDelay(10*time.Second) < 10*time.Second)
+		assert.True(t, initialPollDelay(time.Hour) < maxInitialPollDelay)
+	}
+	assert.Equal(t, time.Duration(0), initialPollDelay(0))
+}
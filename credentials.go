@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pepabo/go-netapp/netapp"
+)
+
+// CredentialProvider returns the username and password of a filer.
+type CredentialProvider interface {
+	Credentials() (username, password string, err error)
+}
+
+// CredentialsConfig selects the credential provider of a filer with type:
+//   - inline: username and password of the filer
+//   - env: environment variables username_env and password_env
+//   - file: files username_file and password_file, e.g. a mounted Kubernetes secret. The username of the
+//     filer is used if username_file is not set.
+//   - http: a secret store returning a JSON object with the keys username_key and password_key, e.g. Vault.
+//     The token, read from token_file if it is not set, is sent in the header token_header.
+type CredentialsConfig struct {
+	Type         string `yaml:"type"`
+	UsernameEnv  string `yaml:"username_env"`
+	PasswordEnv  string `yaml:"password_env"`
+	UsernameFile string `yaml:"username_file"`
+	PasswordFile string `yaml:"password_file"`
+	URL          string `yaml:"url"`
+	Token        string `yaml:"token"`
+	TokenFile    string `yaml:"token_file"`
+	TokenHeader  string `yaml:"token_header"`
+	UsernameKey  string `yaml:"username_key"`
+	PasswordKey  string `yaml:"password_key"`
+}
+
+// newCredentialProvider returns the provider configured for the filer. Filers without credentials config use
+// their username and password. The environment variables NETAPP_USERNAME and NETAPP_PASSWORD are only read
+// with type env, so that the shared credentials are not used for filers by accident.
+func newCredentialProvider(f NetappFiler) (CredentialProvider, error) {
+	c := f.Credentials
+	if c == nil {
+		return &inlineCredentials{f.Username, f.Password}, nil
+	}
+
+	switch c.Type {
+	case "inline":
+		return &inlineCredentials{f.Username, f.Password}, nil
+	case "env":
+		return &envCredentials{usernameEnv: c.UsernameEnv, passwordEnv: c.PasswordEnv}, nil
+	case "file":
+		if c.PasswordFile == "" {
+			return nil, fmt.Errorf("credentials of type file require password_file")
+		}
+		return &fileCredentials{username: f.Username, usernameFile: c.UsernameFile, passwordFile: c.PasswordFile}, nil
+	case "http":
+		if c.URL == "" {
+			return nil, fmt.Errorf("credentials of type http require url")
+		}
+		return &httpCredentials{
+			url:         c.URL,
+			token:       c.Token,
+			tokenFile:   c.TokenFile,
+			tokenHeader: c.TokenHeader,
+			usernameKey: c.UsernameKey,
+			passwordKey: c.PasswordKey,
+			client:      &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown credentials type %q", c.Type)
+}
+
+type inlineCredentials struct {
+	username string
+	password string
+}
+
+func (c *inlineCredentials) Credentials() (string, string, error) {
+	if c.username == "" || c.password == "" {
+		return "", "", fmt.Errorf("username or password not set, use credentials of type env to read them from NETAPP_USERNAME and NETAPP_PASSWORD")
+	}
+	return c.username, c.password, nil
+}
+
+type envCredentials struct {
+	usernameEnv string
+	passwordEnv string
+}
+
+func (c *envCredentials) Credentials() (string, string, error) {
+	usernameEnv, passwordEnv := c.usernameEnv, c.passwordEnv
+	if usernameEnv == "" {
+		usernameEnv = "NETAPP_USERNAME"
+	}
+	if passwordEnv == "" {
+		passwordEnv = "NETAPP_PASSWORD"
+	}
+	username, password := os.Getenv(usernameEnv), os.Getenv(passwordEnv)
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("environment variable %s or %s not set", usernameEnv, passwordEnv)
+	}
+	return username, password, nil
+}
+
+type fileCredentials struct {
+	username     string
+	usernameFile string
+	passwordFile string
+}
+
+func (c *fileCredentials) Credentials() (username, password string, err error) {
+	username = c.username
+	if c.usernameFile != "" {
+		if username, err = readSecretFile(c.usernameFile); err != nil {
+			return
+		}
+	}
+	if password, err = readSecretFile(c.passwordFile); err != nil {
+		return
+	}
+	if username == "" || password == "" {
+		err = fmt.Errorf("username or password not set")
+	}
+	return
+}
+
+// readSecretFile returns the content of a file without the trailing newline.
+func readSecretFile(fileName string) (string, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+type httpCredentials struct {
+	url         string
+	token       string
+	tokenFile   string
+	tokenHeader string
+	usernameKey string
+	passwordKey string
+	client      *http.Client
+}
+
+func (c *httpCredentials) Credentials() (string, string, error) {
+	req, err := http.NewRequest("GET", c.url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	token := c.token
+	if token == "" && c.tokenFile != "" {
+		if token, err = readSecretFile(c.tokenFile); err != nil {
+			return "", "", err
+		}
+	}
+	if token != "" {
+		header := c.tokenHeader
+		if header == "" {
+			header = "X-Vault-Token"
+		}
+		req.Header.Set(header, token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("secret store %s returned status %d", c.url, resp.StatusCode)
+	}
+	var secret map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", "", fmt.Errorf("decode secret from %s: %v", c.url, err)
+	}
+
+	usernameKey, passwordKey := c.usernameKey, c.passwordKey
+	if usernameKey == "" {
+		usernameKey = "username"
+	}
+	if passwordKey == "" {
+		passwordKey = "password"
+	}
+	// Vault nests the secret in "data", the KV version 2 engine twice.
+	for {
+		data, ok := secret["data"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		if _, ok := secret[passwordKey]; ok {
+			break
+		}
+		secret = data
+	}
+	username, _ := secret[usernameKey].(string)
+	password, _ := secret[passwordKey].(string)
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("secret from %s has no %s or %s", c.url, usernameKey, passwordKey)
+	}
+	return username, password, nil
+}
+
+const (
+	minAuthBackoff = time.Minute
+	maxAuthBackoff = 30 * time.Minute
+)
+
+// zapiAuthErrnos are the errnos of failed ZAPI results that are authorization failures. A filer returns
+// them with HTTP 200, e.g. when the role of the user has been changed along with its password.
+var zapiAuthErrnos = map[int]bool{
+	13003: true, // EAPIPRIVILEGE: insufficient privileges
+}
+
+// isAuthError returns whether err is the error of a request rejected with HTTP 401, or of a ZAPI result
+// failed with an authorization errno.
+func isAuthError(err error) bool {
+	switch e := err.(type) {
+	case *httpStatusError:
+		return e.StatusCode == http.StatusUnauthorized
+	case *zapiError:
+		return zapiAuthErrnos[e.Errno]
+	}
+	// go-netapp only returns the status in the error message
+	return err != nil && strings.Contains(err.Error(), "Http Error status 401")
+}
+
+// checkAuth returns an error while requests to the filer are held back after an authentication failure, so
+// that the account of the exporter is not locked by retrying wrong credentials. Once the backoff has
+// passed, the credentials are read again before the next request is made.
+func (f *NetappFilerClient) checkAuth() error {
+	c := f.conn
+	c.Lock()
+	if c.authBlockedUntil.IsZero() {
+		c.Unlock()
+		return nil
+	}
+	if wait := time.Until(c.authBlockedUntil); wait > 0 {
+		c.Unlock()
+		return fmt.Errorf("%s: authentication failed, next attempt in %s", f.Host, wait.Round(time.Second))
+	}
+	c.authBlockedUntil = time.Time{}
+	c.Unlock()
+
+	c.renewCredentials(f.Name)
+	return nil
+}
+
+// authFailed is called when a request made with client has been rejected as unauthorized. The credentials
+// are read again and the client is rebuilt if they have changed. Otherwise requests are held back with an
+// increasing backoff.
+func (f *NetappFilerClient) authFailed(client *netapp.Client) {
+	c := f.conn
+	if !c.isCurrent(client) {
+		return
+	}
+	if c.renewCredentials(f.Name) {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	// checked again, since the lock was not held while reading the credentials
+	if client != c.client || time.Now().Before(c.authBlockedUntil) {
+		return
+	}
+	c.authBackoff *= 2
+	if c.authBackoff < minAuthBackoff {
+		c.authBackoff = minAuthBackoff
+	}
+	if c.authBackoff > maxAuthBackoff {
+		c.authBackoff = maxAuthBackoff
+	}
+	c.authBlockedUntil = time.Now().Add(c.authBackoff)
+	logger.Warnf("%s: authentication failed with unchanged credentials, next attempt in %s", f.Name, c.authBackoff)
+}
+
+// isCurrent returns false if other collectors of the filer have failed at the same time, i.e. the client
+// has been rebuilt already or requests are held back.
+func (c *filerConn) isCurrent(client *netapp.Client) bool {
+	c.Lock()
+	defer c.Unlock()
+	return client == c.client && !time.Now().Before(c.authBlockedUntil)
+}
+
+// authSucceeded resets the backoff after a successful request.
+func (f *NetappFilerClient) authSucceeded() {
+	f.conn.Lock()
+	f.conn.authBackoff = 0
+	f.conn.Unlock()
+}
+
+// renewCredentials reads the credentials from the provider and rebuilds the client if they have changed.
+// It returns whether the client has been rebuilt. c must not be locked: reading the credentials may take
+// up to the timeout of the secret store, and the collectors of the filer are not held up meanwhile.
+func (c *filerConn) renewCredentials(name string) bool {
+	username, password, err := c.provider.Credentials()
+	if err != nil {
+		logger.Errorf("%s: read credentials: %v", name, err)
+		return false
+	}
+	if u, p := c.credentials(); username == u && password == p {
+		return false
+	}
+	client, err := c.newClient(username, password)
+	if err != nil {
+		logger.Errorf("%s: rebuild client: %v", name, err)
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	// another collector may have rebuilt the client with the same credentials meanwhile
+	if username == c.username && password == c.password {
+		return true
+	}
+	logger.Infof("%s: credentials changed, rebuilding client", name)
+	c.username, c.password = username, password
+	c.client = client
+	return true
+}
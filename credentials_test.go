@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func credentials(t *testing.T, f NetappFiler) (string, string, error) {
+	p, err := newCredentialProvider(f)
+	assert.NoError(t, err)
+	return p.Credentials()
+}
+
+func TestCredentialProviders(t *testing.T) {
+	username, password, err := credentials(t, NetappFiler{Username: "user", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+
+	os.Setenv("LAB_USERNAME", "lab-user")
+	os.Setenv("LAB_PASSWORD", "lab-secret")
+	defer os.Unsetenv("LAB_USERNAME")
+	defer os.Unsetenv("LAB_PASSWORD")
+	username, password, err = credentials(t, NetappFiler{
+		Credentials: &CredentialsConfig{Type: "env", UsernameEnv: "LAB_USERNAME", PasswordEnv: "LAB_PASSWORD"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "lab-user", username)
+	assert.Equal(t, "lab-secret", password)
+	_, _, err = credentials(t, NetappFiler{Credentials: &CredentialsConfig{Type: "env", PasswordEnv: "UNSET_PASSWORD"}})
+	assert.Error(t, err)
+
+	// the environment is only read with type env
+	os.Setenv("NETAPP_USERNAME", "shared-user")
+	os.Setenv("NETAPP_PASSWORD", "shared-secret")
+	defer os.Unsetenv("NETAPP_USERNAME")
+	defer os.Unsetenv("NETAPP_PASSWORD")
+	_, _, err = credentials(t, NetappFiler{Username: "user"})
+	assert.Error(t, err)
+	username, _, err = credentials(t, NetappFiler{Credentials: &CredentialsConfig{Type: "env"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "shared-user", username)
+
+	dir, err := ioutil.TempDir("", "credentials")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	ioutil.WriteFile(passwordFile, []byte("file-secret\n"), 0600)
+	username, password, err = credentials(t, NetappFiler{
+		Username:    "user",
+		Credentials: &CredentialsConfig{Type: "file", PasswordFile: passwordFile},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "file-secret", password)
+
+	_, err = newCredentialProvider(NetappFiler{Credentials: &CredentialsConfig{Type: "unknown"}})
+	assert.Error(t, err)
+}
+
+func TestHTTPCredentials(t *testing.T) {
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/lab":
+			w.Write([]byte(`{"data": {"data": {"username": "vault-user", "password": "vault-secret"}}}`))
+		case "/v1/secret/flat":
+			w.Write([]byte(`{"user": "flat-user", "pass": "flat-secret"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer store.Close()
+
+	username, password, err := credentials(t, NetappFiler{
+		Credentials: &CredentialsConfig{Type: "http", URL: store.URL + "/v1/secret/data/lab", Token: "token"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "vault-user", username)
+	assert.Equal(t, "vault-secret", password)
+
+	username, password, err = credentials(t, NetappFiler{
+		Credentials: &CredentialsConfig{
+			Type:        "http",
+			URL:         store.URL + "/v1/secret/flat",
+			Token:       "token",
+			UsernameKey: "user",
+			PasswordKey: "pass",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "flat-user", username)
+	assert.Equal(t, "flat-secret", password)
+
+	_, _, err = credentials(t, NetappFiler{
+		Credentials: &CredentialsConfig{Type: "http", URL: store.URL + "/v1/secret/data/lab", Token: "wrong"},
+	})
+	assert.Error(t, err)
+}
+
+func TestCredentialRotation(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<netapp><results status="passed"><num-records>0</num-records></results></netapp>`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "credentials")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	ioutil.WriteFile(passwordFile, []byte("old"), 0600)
+
+	f, err := NewNetappClient(NetappFiler{
+		Name:        "filer",
+		Host:        strings.TrimPrefix(server.URL, "https://"),
+		Username:    "user",
+		Credentials: &CredentialsConfig{Type: "file", PasswordFile: passwordFile},
+	}, ClientOptions{})
+	assert.NoError(t, err)
+	query := func() error {
+		if err := f.checkAuth(); err != nil {
+			return err
+		}
+		client := f.NetappClient()
+		_, err := f.QueryNodes(context.Background(), &systemNodeGetIterOptions{})
+		if isAuthError(err) {
+			f.authFailed(client)
+		}
+		return err
+	}
+
+	// unchanged credentials are not retried before the backoff has passed
+	err = query()
+	assert.True(t, isAuthError(err))
+	err = query()
+	assert.Error(t, err)
+	assert.False(t, isAuthError(err))
+	assert.Equal(t, minAuthBackoff, f.conn.authBackoff)
+
+	// rotated credentials are read once the backoff has passed
+	ioutil.WriteFile(passwordFile, []byte("new"), 0600)
+	f.conn.authBlockedUntil = time.Now()
+	assert.NoError(t, query())
+
+	// rotated credentials are read right after an authentication failure
+	ioutil.WriteFile(passwordFile, []byte("old"), 0600)
+	f.conn.authBackoff = 0
+	f.conn.renewCredentials(f.Name)
+	ioutil.WriteFile(passwordFile, []byte("new"), 0600)
+	assert.True(t, isAuthError(query()))
+	assert.NoError(t, query())
+}
+
+func TestZAPIAuthError(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+	f := newFakeFiler(t, s)
+
+	s.SetError("volume-get-iter", 13003, "Insufficient privileges: user 'user' does not have read access to this resource")
+	client := f.NetappClient()
+	_, err := f.QueryVolumes(context.Background(), &netapp.VolumeOptions{})
+	assert.True(t, isAuthError(err))
+	f.authFailed(client)
+	assert.Equal(t, minAuthBackoff, f.conn.authBackoff)
+	assert.Error(t, f.checkAuth())
+
+	// other failed results are no authentication failures
+	s.SetError("aggr-get-iter", 13005, "Unable to find API: aggr-get-iter")
+	_, err = f.QueryAggregates(context.Background(), &netapp.AggrOptions{})
+	assert.Error(t, err)
+	assert.False(t, isAuthError(err))
+}
+
+type blockingCredentials struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCredentials) Credentials() (string, string, error) {
+	close(c.entered)
+	<-c.release
+	return "user", "new", nil
+}
+
+// Reading the credentials from a slow secret store does not hold up the other collectors of the filer.
+func TestRenewCredentialsUnlocked(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+	f := newFakeFiler(t, s)
+	provider := &blockingCredentials{entered: make(chan struct{}), release: make(chan struct{})}
+	f.conn.provider = provider
+	client := f.NetappClient()
+
+	done := make(chan struct{})
+	go func() {
+		f.authFailed(client)
+		close(done)
+	}()
+	<-provider.entered
+	_, err := f.QueryAggregates(context.Background(), &netapp.AggrOptions{})
+	assert.NoError(t, err)
+
+	close(provider.release)
+	<-done
+	assert.False(t, client == f.NetappClient())
+	_, password := f.conn.credentials()
+	assert.Equal(t, "new", password)
+}
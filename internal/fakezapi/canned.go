@@ -0,0 +1,64 @@
+package fakezapi
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pepabo/go-netapp/netapp"
+)
+
+// CannedAggregates returns two aggregates of 1 TiB on two nodes, aggr1 used by 25% and aggr2 by 50%.
+func CannedAggregates() []netapp.AggrInfo {
+	const tib = 1 << 40
+	aggr := func(name, node string, used int) netapp.AggrInfo {
+		return netapp.AggrInfo{
+			AggregateName: name,
+			AggrOwnershipAttributes: &netapp.AggrOwnershipAttributes{
+				OwnerName: node,
+			},
+			AggrSpaceAttributes: &netapp.AggrSpaceAttributes{
+				SizeTotal:           tib,
+				SizeUsed:            used,
+				SizeAvailable:       tib - used,
+				PercentUsedCapacity: strconv.Itoa(used * 100 / tib),
+				PhysicalUsed:        used,
+				PhysicalUsedPercent: used * 100 / tib,
+			},
+		}
+	}
+	return []netapp.AggrInfo{
+		aggr("aggr1", "node1", tib/4),
+		aggr("aggr2", "node2", tib/2),
+	}
+}
+
+// CannedVolumes returns n online volumes vol000, vol001, ... of 100 GiB in the vserver vs1, with a share
+// comment as set by Manila. Volume i uses i GiB.
+func CannedVolumes(n int) []netapp.VolumeInfo {
+	const gib = 1 << 30
+	vols := make([]netapp.VolumeInfo, n)
+	for i := range vols {
+		name := fmt.Sprintf("vol%03d", i)
+		vols[i] = netapp.VolumeInfo{
+			VolumeIDAttributes: &netapp.VolumeIDAttributes{
+				Name:              name,
+				OwningVserverName: "vs1",
+				Comment:           fmt.Sprintf("share_id: share-%03d, share_name: %s, project: project1", i, name),
+			},
+			VolumeSpaceAttributes: &netapp.VolumeSpaceAttributes{
+				Size:               100 * gib,
+				SizeTotal:          strconv.Itoa(95 * gib),
+				SizeUsed:           strconv.Itoa(i * gib),
+				SizeAvailable:      strconv.Itoa((95 - i) * gib),
+				PercentageSizeUsed: strconv.Itoa(i * 100 / 95),
+			},
+			VolumeSisAttributes: &netapp.VolumeSisAttributes{
+				PercentageTotalSpaceSaved: "10",
+			},
+			VolumeStateAttributes: &netapp.VolumeStateAttributes{
+				State: "online",
+			},
+		}
+	}
+	return vols
+}
@@ -0,0 +1,234 @@
+// Package fakezapi provides a fake of the ZAPI endpoint of a filer for tests. It serves aggr-get-iter and
+// volume-get-iter from canned records with next-tag paging, and can inject errors and latency.
+package fakezapi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pepabo/go-netapp/netapp"
+)
+
+// Path is the path of the ZAPI endpoint.
+const Path = "/servlets/netapp.servlets.admin.XMLrequest_filer"
+
+// defaultMaxRecords is used for requests without max-records, like ONTAP does.
+const defaultMaxRecords = 20
+
+// recordElements are the element names of the records of the APIs in attributes-list.
+var recordElements = map[string]string{
+	"aggr-get-iter":   "aggr-attributes",
+	"volume-get-iter": "volume-attributes",
+}
+
+type zapiError struct {
+	errno  int
+	reason string
+}
+
+// Server is a fake filer. Its methods may be called while requests are served.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	records     map[string][]interface{}
+	zapiErrors  map[string]zapiError
+	httpErrors  map[string]int
+	latency     time.Duration
+	requests    map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+// NewServer starts a fake filer serving CannedAggregates and CannedVolumes(50) over TLS with a self-signed
+// certificate.
+func NewServer() *Server {
+	s := &Server{
+		records:    make(map[string][]interface{}),
+		zapiErrors: make(map[string]zapiError),
+		httpErrors: make(map[string]int),
+		requests:   make(map[string]int),
+	}
+	s.SetAggregates(CannedAggregates()...)
+	s.SetVolumes(CannedVolumes(50)...)
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Host returns the host of the server in the form expected by the filer config.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// SetAggregates replaces the records of aggr-get-iter.
+func (s *Server) SetAggregates(aggrs ...netapp.AggrInfo) {
+	records := make([]interface{}, len(aggrs))
+	for i := range aggrs {
+		records[i] = aggrs[i]
+	}
+	s.setRecords("aggr-get-iter", records)
+}
+
+// SetVolumes replaces the records of volume-get-iter.
+func (s *Server) SetVolumes(vols ...netapp.VolumeInfo) {
+	records := make([]interface{}, len(vols))
+	for i := range vols {
+		records[i] = vols[i]
+	}
+	s.setRecords("volume-get-iter", records)
+}
+
+func (s *Server) setRecords(api string, records []interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[api] = records
+}
+
+// SetError makes the api fail with a ZAPI error. An errno of 0 removes the error.
+func (s *Server) SetError(api string, errno int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errno == 0 {
+		delete(s.zapiErrors, api)
+		return
+	}
+	s.zapiErrors[api] = zapiError{errno, reason}
+}
+
+// SetHTTPStatus makes the api fail with an http status. A status of 0 or 200 removes the error.
+func (s *Server) SetHTTPStatus(api string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == 0 || status == http.StatusOK {
+		delete(s.httpErrors, api)
+		return
+	}
+	s.httpErrors[api] = status
+}
+
+// SetLatency delays every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the number of requests of the api, including failed ones.
+func (s *Server) Requests(api string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[api]
+}
+
+// MaxInFlight returns the maximal number of requests that have been served at the same time.
+func (s *Server) MaxInFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxInFlight
+}
+
+type request struct {
+	XMLName xml.Name `xml:"netapp"`
+	API     struct {
+		XMLName    xml.Name
+		MaxRecords int    `xml:"max-records"`
+		Tag        string `xml:"tag"`
+	} `xml:",any"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path || r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	var req request
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	api := req.API.XMLName.Local
+
+	s.mu.Lock()
+	s.requests[api]++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	latency := s.latency
+	httpStatus, hasHTTPError := s.httpErrors[api]
+	zapiErr, hasZAPIError := s.zapiErrors[api]
+	records, known := s.records[api]
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(latency)
+
+	switch {
+	case hasHTTPError:
+		w.WriteHeader(httpStatus)
+	case hasZAPIError:
+		writeResults(w, fmt.Sprintf(`<results status="failed" errno="%d" reason="%s"/>`, zapiErr.errno, escape(zapiErr.reason)))
+	case !known:
+		writeResults(w, fmt.Sprintf(`<results status="failed" errno="13005" reason="Unable to find API: %s"/>`, escape(api)))
+	default:
+		s.writePage(w, api, records, req.API.MaxRecords, req.API.Tag)
+	}
+}
+
+// writePage writes the records from the offset in tag. The next-tag is the offset of the next page.
+func (s *Server) writePage(w http.ResponseWriter, api string, records []interface{}, maxRecords int, tag string) {
+	if maxRecords <= 0 {
+		maxRecords = defaultMaxRecords
+	}
+	start := 0
+	if tag != "" {
+		var err error
+		if start, err = strconv.Atoi(tag); err != nil || start < 0 || start > len(records) {
+			writeResults(w, fmt.Sprintf(`<results status="failed" errno="13001" reason="invalid tag %s"/>`, escape(tag)))
+			return
+		}
+	}
+	end := start + maxRecords
+	if end > len(records) {
+		end = len(records)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<results status="passed"><attributes-list>`)
+	enc := xml.NewEncoder(&b)
+	for _, r := range records[start:end] {
+		if err := enc.EncodeElement(r, xml.StartElement{Name: xml.Name{Local: recordElements[api]}}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	enc.Flush()
+	b.WriteString(`</attributes-list>`)
+	fmt.Fprintf(&b, `<num-records>%d</num-records>`, end-start)
+	if end < len(records) {
+		fmt.Fprintf(&b, `<next-tag>%d</next-tag>`, end)
+	}
+	b.WriteString(`</results>`)
+	writeResults(w, b.String())
+}
+
+func writeResults(w http.ResponseWriter, results string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><netapp version="1.7" xmlns="http://www.netapp.com/filer/admin">%s</netapp>`, results)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
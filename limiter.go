@@ -0,0 +1,36 @@
+package main
+
+import "context"
+
+// fetchLimiter limits the number of concurrent fetches. Waiting fetches are admitted in the order they
+// arrived, so that no filer is starved by others. A nil fetchLimiter does not limit anything.
+type fetchLimiter chan struct{}
+
+// globalFetchLimiter limits the fetches across all filers. It is set up in main().
+var globalFetchLimiter fetchLimiter
+
+func newFetchLimiter(n int) fetchLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(fetchLimiter, n)
+}
+
+// acquire waits for a free slot. It returns the error of ctx when ctx is done before.
+func (l fetchLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l fetchLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
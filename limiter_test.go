@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchLimiter(t *testing.T) {
+	l := newFetchLimiter(2)
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.acquire(context.Background())
+			defer l.release()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, maxRunning <= 2)
+
+	// a nil limiter does not block
+	var unlimited fetchLimiter
+	unlimited.acquire(context.Background())
+	unlimited.acquire(context.Background())
+	unlimited.release()
+
+	// waiting is aborted when the context is done
+	l.acquire(context.Background())
+	l.acquire(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, l.acquire(ctx))
+}
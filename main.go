@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -18,58 +23,73 @@ import (
 
 // Parameter
 var (
-	configFile    = kingpin.Flag("config", "Config file").Short('c').Default("./netapp_filers.yaml").String()
-	listenAddress = kingpin.Flag("listen", "Listen address").Short('l').Default("0.0.0.0").String()
-	debug         = kingpin.Flag("debug", "Debug mode").Short('d').Bool()
-	logger        = logrus.New()
+	configFile           = kingpin.Flag("config", "Config file").Short('c').Default("./netapp_filers.yaml").String()
+	listenAddress        = kingpin.Flag("listen", "Listen address").Short('l').Default("0.0.0.0").String()
+	debug                = kingpin.Flag("debug", "Debug mode").Short('d').Bool()
+	shutdownTimeout      = kingpin.Flag("shutdown-timeout", "Time to wait for scrapes and fetches to finish on shutdown").Default("30s").Duration()
+	configCheckInterval  = kingpin.Flag("config-check-interval", "Interval of checking the config file for changes").Default("1m").Duration()
+	maxConcurrentFetches = kingpin.Flag("max-concurrent-fetches", "Maximum number of concurrent fetches across all filers (0: unlimited)").Default("10").Int()
+	recordDir            = kingpin.Flag("record", "Directory to record the requests to the filers and their responses in").PlaceHolder("DIR").String()
+	replayDir            = kingpin.Flag("replay", "Directory to replay recorded responses from instead of requesting the filers").PlaceHolder("DIR").String()
 
-	filers []NetappFilerClient
+	logger = logrus.New()
 )
 
 type myFormatter struct{}
 
 func init() {
+	logger.Out = os.Stdout
+	logger.SetFormatter(new(myFormatter))
+}
+
+func main() {
+	// flags are parsed here rather than in init(), so that `go test` flags do not reach kingpin.
 	kingpin.Parse()
 
 	if os.Getenv("DEV") != "" {
 		*debug = true
 	}
-
-	logger.Out = os.Stdout
-	logger.SetFormatter(new(myFormatter))
 	if *debug {
 		logger.Level = logrus.DebugLevel
 	} else {
 		logger.Level = logrus.InfoLevel
 	}
-	for _, f := range filers {
-		logger.Printf("Host (%s) loaded", f.Host)
+
+	// the poll delays of exporters started at the same time differ
+	rand.Seed(time.Now().UnixNano())
+	globalFetchLimiter = newFetchLimiter(*maxConcurrentFetches)
+	var clientOptions ClientOptions
+	switch {
+	case *recordDir != "" && *replayDir != "":
+		logger.Fatal("--record and --replay cannot be used together")
+	case *recordDir != "":
+		clientOptions = recordOptions(*recordDir)
+	case *replayDir != "":
+		clientOptions = replayOptions(*replayDir)
 	}
-}
 
-func main() {
+	reg := prometheus.NewPedanticRegistry()
+	filerRegistry := NewFilerRegistry(reg, clientOptions)
+
 	// try loading filers every 5 seconds until successful
 	for {
-		filers = loadFilers()
-		if len(filers) == 0 {
+		filers, err := loadFilers()
+		if err == nil {
+			err = filerRegistry.Reconcile(filers)
+		}
+		if err != nil {
+			logger.Error(err)
+		}
+		if filerRegistry.Len() == 0 {
 			time.Sleep(5 * time.Second)
 			continue
 		}
 		break
 	}
 
-	reg := prometheus.NewPedanticRegistry()
-
-	for _, f := range filers {
-		logger.Printf("Register filer: Name=%s Host=%s Username=%s AvailabilityZone=%s",
-			f.Name, f.Host, f.Username, f.AvailabilityZone)
-		cc := NewNetappCollector(f)
-		labels := prometheus.Labels{
-			"filer":             f.Name,
-			"availability_zone": f.AvailabilityZone,
-		}
-		prometheus.WrapRegistererWith(labels, reg).MustRegister(cc)
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchConfig(ctx, filerRegistry, *configCheckInterval)
 
 	reg.MustRegister(
 		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
@@ -77,54 +97,176 @@ func main() {
 	)
 
 	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
-	logger.Fatal(http.ListenAndServe(*listenAddress+":9108", nil))
+	http.Handle("/probe", probeHandler(filerRegistry))
+
+	server := &http.Server{Addr: *listenAddress + ":9108"}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	logger.Infof("%s received, shutting down", <-stop)
+	cancel()
+	shutdown(server, filerRegistry, *shutdownTimeout)
+}
+
+// shutdown finishes the running scrapes before stopping the fetches, so that scrapes are not truncated.
+// Both share the timeout.
+func shutdown(server *http.Server, r *FilerRegistry, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Errorf("shutdown http server: %v", err)
+	}
+	if err := r.Shutdown(ctx); err != nil {
+		logger.Errorf("shutdown fetches: %v", err)
+	}
+	logger.Info("shutdown complete")
+	if f, ok := logger.Out.(*os.File); ok {
+		f.Sync()
+	}
+}
+
+// watchConfig reloads the filers on SIGHUP and every interval, so that changes of the config file take
+// effect without restart. It returns when ctx is done.
+func watchConfig(ctx context.Context, r *FilerRegistry, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// the config reconciled last; the clients are only created anew when it changes
+	var current []NetappFiler
+	var reconciled bool
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Info("SIGHUP received, reloading filers")
+		case <-ticker.C:
+		}
+		filers, err := loadFilers()
+		if err != nil {
+			logger.Errorf("keeping current filers: %v", err)
+			continue
+		}
+		// filers that failed to load are retried even if the config is unchanged
+		if reconciled && reflect.DeepEqual(filers, current) {
+			logger.Debug("config unchanged")
+			continue
+		}
+		if err := r.Reconcile(filers); err != nil {
+			logger.Errorf("reconcile filers: %v", err)
+			reconciled = false
+			continue
+		}
+		current, reconciled = filers, true
+	}
 }
 
-func loadFilers() (filers []NetappFilerClient) {
+// loadFilers reads the config of the filers. Their clients are created by FilerRegistry.Reconcile.
+func loadFilers() (filers []NetappFiler, err error) {
 	if os.Getenv("DEV") != "" {
-		filers = loadFilerFromEnv()
+		filers, err = loadFilerFromEnv()
 	} else {
-		filers = loadFilerFromFile(*configFile)
+		filers, err = loadFilerFromFile(*configFile)
 	}
 	return
 }
 
-func loadFilerFromFile(fileName string) (c []NetappFilerClient) {
-	var filers []NetappFiler
-	if yamlFile, err := ioutil.ReadFile(fileName); err != nil {
-		logger.Fatal("read file ", fileName, err)
-	} else {
-		if err := yaml.Unmarshal(yamlFile, &filers); err != nil {
-			logger.Fatal("unmarshal yaml struct", err)
-		}
+func loadFilerFromFile(fileName string) ([]NetappFiler, error) {
+	yamlFile, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read file %s: %v", fileName, err)
+	}
+	filers, err := parseFilers(yamlFile)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal yaml struct: %v", err)
 	}
 
 	for _, f := range filers {
-		if f.Username == "" || f.Password == "" {
-			username, password := loadAuthFromEnv()
-			f.Username = username
-			f.Password = password
+		for _, cc := range f.Collectors {
+			if _, ok := collectorFactories[cc.Name]; !ok {
+				return nil, fmt.Errorf("filer %s: unknown collector %q", f.Name, cc.Name)
+			}
+			if f.Backend == backendREST && !isRESTCollector(cc.Name) {
+				return nil, fmt.Errorf("filer %s: collector %q is not available with the rest backend", f.Name, cc.Name)
+			}
 		}
-		c = append(c, NewNetappClient(f))
 	}
-	return
+	return filers, nil
 }
 
-func loadFilerFromEnv() (c []NetappFilerClient) {
-	name := os.Getenv("NETAPP_NAME")
-	host := os.Getenv("NETAPP_HOST")
-	username := os.Getenv("NETAPP_USERNAME")
-	password := os.Getenv("NETAPP_PASSWORD")
-	az := os.Getenv("NETAPP_AZ")
-	f := NewNetappClient(NetappFiler{name, host, username, password, az})
-	c = append(c, f)
-	return
+// FilerConfig is the format of the config file with global settings. The config file may also be a plain
+// list of filers.
+type FilerConfig struct {
+	MaxAge               time.Duration `yaml:"max_age"`
+	MinInterval          time.Duration `yaml:"min_interval"`
+	ServeStale           bool          `yaml:"serve_stale"`
+	Backend              string        `yaml:"backend"`
+	TLS                  *TLSConfig    `yaml:"tls"`
+	Timeout              time.Duration `yaml:"timeout"`
+	MaxConcurrentFetches int           `yaml:"max_concurrent_fetches"`
+	Filers               []NetappFiler `yaml:"filers"`
 }
 
-func loadAuthFromEnv() (username, password string) {
-	username = os.Getenv("NETAPP_USERNAME")
-	password = os.Getenv("NETAPP_PASSWORD")
-	return
+// parseFilers reads the filers from the config file and applies the global settings to them.
+func parseFilers(data []byte) ([]NetappFiler, error) {
+	var config FilerConfig
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var err error
+	if _, ok := raw.([]interface{}); ok {
+		err = yaml.Unmarshal(data, &config.Filers)
+	} else {
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range config.Filers {
+		f := &config.Filers[i]
+		if f.MaxAge == 0 {
+			f.MaxAge = config.MaxAge
+		}
+		if f.MinInterval == 0 {
+			f.MinInterval = config.MinInterval
+		}
+		if f.ServeStale == nil {
+			f.ServeStale = &config.ServeStale
+		}
+		if f.Backend == "" {
+			f.Backend = config.Backend
+		}
+		if f.TLS == nil {
+			f.TLS = config.TLS
+		}
+		if f.Timeout == 0 {
+			f.Timeout = config.Timeout
+		}
+		if f.MaxConcurrentFetches == 0 {
+			f.MaxConcurrentFetches = config.MaxConcurrentFetches
+		}
+	}
+	return config.Filers, nil
+}
+
+func loadFilerFromEnv() ([]NetappFiler, error) {
+	return []NetappFiler{{
+		Name:             os.Getenv("NETAPP_NAME"),
+		Host:             os.Getenv("NETAPP_HOST"),
+		AvailabilityZone: os.Getenv("NETAPP_AZ"),
+		Credentials:      &CredentialsConfig{Type: "env"},
+	}}, nil
 }
 
 func (f *myFormatter) Format(entry *logrus.Entry) ([]byte, error) {
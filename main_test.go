@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilers(t *testing.T) {
+	filers, err := parseFilers([]byte(`
+- name: filer1
+  host: filer1.example.com
+`))
+	assert.NoError(t, err)
+	assert.Len(t, filers, 1)
+	assert.Equal(t, "filer1", filers[0].Name)
+	assert.Equal(t, time.Duration(0), filers[0].MaxAge)
+
+	filers, err = parseFilers([]byte(`
+max_age: 10m
+min_interval: 5m
+serve_stale: true
+max_concurrent_fetches: 4
+timeout: 3m
+filers:
+  - name: filer1
+    host: filer1.example.com
+    min_interval: 30s
+    collectors:
+      - aggregate
+      - name: volume
+        max_age: 1m
+        serve_stale: false
+`))
+	assert.NoError(t, err)
+	assert.Len(t, filers, 1)
+	assert.Equal(t, 10*time.Minute, filers[0].MaxAge)
+	assert.Equal(t, 30*time.Second, filers[0].MinInterval)
+	assert.True(t, *filers[0].ServeStale)
+	assert.Equal(t, 4, filers[0].MaxConcurrentFetches)
+	assert.Equal(t, 3*time.Minute, filers[0].Timeout)
+	assert.Equal(t, CollectorConfig{Name: "aggregate"}, filers[0].Collectors[0])
+	assert.Equal(t, "volume", filers[0].Collectors[1].Name)
+	assert.Equal(t, time.Minute, filers[0].Collectors[1].MaxAge)
+	assert.False(t, *filers[0].Collectors[1].ServeStale)
+}
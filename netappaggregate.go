@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -121,7 +122,7 @@ func (a *AggrCollector) SaveData(data []interface{}) error {
 	return nil
 }
 
-func (a *AggrCollector) Fetch() (aggregates []interface{}, err error) {
+func (a *AggrCollector) Fetch(ctx context.Context) (aggregates []interface{}, err error) {
 	ff := new(bool)
 	*ff = false
 	opts := &netapp.AggrOptions{
@@ -136,7 +137,7 @@ func (a *AggrCollector) Fetch() (aggregates []interface{}, err error) {
 		},
 	}
 
-	aggrs, err := a.Filer.QueryAggregates(opts)
+	aggrs, err := a.Filer.QueryAggregates(ctx, opts)
 
 	if err == nil {
 		logger.Printf("%s: %d aggregates fetched", a.Filer.Host, len(aggrs))
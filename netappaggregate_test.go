@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggrCollectorFetch(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+
+	c := &AggrCollector{Filer: newFakeFiler(t, s)}
+	data, err := c.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, data, 2)
+
+	a := data[1].(*NetappAggregate)
+	assert.Equal(t, "aggr2", a.Name)
+	assert.Equal(t, "node2", a.OwnerName)
+	assert.Equal(t, float64(1<<40), a.SizeTotal)
+	assert.Equal(t, float64(50), a.PercentUsedCapacity)
+}
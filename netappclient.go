@@ -1,30 +1,177 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/pepabo/go-netapp/netapp"
 )
 
 type NetappFilerClient struct {
 	NetappFiler
-	NetappClient *netapp.Client
+	conn *filerConn
+}
+
+// filerConn is shared by the copies of a NetappFilerClient, so that a client rebuilt with new credentials
+// is used by all collectors of the filer.
+type filerConn struct {
+	sync.Mutex
+	client    *netapp.Client
+	provider  CredentialProvider
+	host      string
+	username  string
+	password  string
+	transport http.RoundTripper
+	backend   backend
+
+	authBackoff      time.Duration
+	authBlockedUntil time.Time
 }
 
 type NetappFiler struct {
-	Name             string `yaml:"name"`
-	Host             string `yaml:"host"`
-	Username         string `yaml:"username"`
-	Password         string `yaml:"password"`
-	AvailabilityZone string `yaml:"availability_zone"`
+	Name                 string             `yaml:"name"`
+	Host                 string             `yaml:"host"`
+	Username             string             `yaml:"username"`
+	Password             string             `yaml:"password"`
+	AvailabilityZone     string             `yaml:"availability_zone"`
+	MaxAge               time.Duration      `yaml:"max_age"`
+	MinInterval          time.Duration      `yaml:"min_interval"`
+	ServeStale           *bool              `yaml:"serve_stale"`
+	Timeout              time.Duration      `yaml:"timeout"`
+	MaxConcurrentFetches int                `yaml:"max_concurrent_fetches"`
+	Backend              string             `yaml:"backend"`
+	Credentials          *CredentialsConfig `yaml:"credentials"`
+	TLS                  *TLSConfig         `yaml:"tls"`
+	Collectors           []CollectorConfig  `yaml:"collectors"`
+}
+
+// TLSConfig configures the connection to the filer. Certificates are not verified unless verify is set, so
+// that filers with self-signed certificates keep working.
+type TLSConfig struct {
+	Verify     bool   `yaml:"verify"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+}
+
+// CollectorConfig is an entry of the collectors list of a filer. It is either the name of the collector
+// or a map with the name and intervals, serve_stale or timeout overriding those of the filer.
+type CollectorConfig struct {
+	Name        string        `yaml:"name"`
+	MaxAge      time.Duration `yaml:"max_age"`
+	MinInterval time.Duration `yaml:"min_interval"`
+	ServeStale  *bool         `yaml:"serve_stale"`
+	Timeout     time.Duration `yaml:"timeout"`
 }
 
-func NewNetappClient(f NetappFiler) NetappFilerClient {
+func (c *CollectorConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&c.Name); err == nil {
+		return nil
+	}
+	type plain CollectorConfig
+	return unmarshal((*plain)(c))
+}
+
+// ClientOptions are the settings of the clients that do not come from the config of the filers, like
+// recording and replaying requests. They are chosen in main().
+type ClientOptions struct {
+	// WrapTransport wraps the transport of the filer with the given name, if set.
+	WrapTransport func(filer string, t http.RoundTripper) http.RoundTripper
+	// Credentials replaces the credential provider configured for the filer, if set.
+	Credentials CredentialProvider
+}
+
+// NewNetappClient reads the credentials of the filer from its provider and creates the client with them.
+func NewNetappClient(f NetappFiler, opts ClientOptions) (NetappFilerClient, error) {
+	provider := opts.Credentials
+	if provider == nil {
+		var err error
+		if provider, err = newCredentialProvider(f); err != nil {
+			return NetappFilerClient{}, fmt.Errorf("filer %s: %v", f.Name, err)
+		}
+	}
+	username, password, err := provider.Credentials()
+	if err != nil {
+		return NetappFilerClient{}, fmt.Errorf("filer %s: read credentials: %v", f.Name, err)
+	}
+
+	conn := &filerConn{
+		provider: provider,
+		host:     f.Host,
+		username: username,
+		password: password,
+	}
+	if f.TLS != nil {
+		tlsConfig, err := newTLSConfig(f.TLS)
+		if err != nil {
+			return NetappFilerClient{}, fmt.Errorf("filer %s: %v", f.Name, err)
+		}
+		conn.transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+	if opts.WrapTransport != nil {
+		transport := conn.transport
+		if transport == nil {
+			transport = defaultTransport()
+		}
+		conn.transport = opts.WrapTransport(f.Name, transport)
+	}
+	if conn.client, err = conn.newClient(conn.username, conn.password); err != nil {
+		return NetappFilerClient{}, fmt.Errorf("filer %s: %v", f.Name, err)
+	}
+	switch f.Backend {
+	case "", backendZAPI:
+		conn.backend = zapiBackend{conn}
+	case backendREST:
+		conn.backend = newRESTBackend(conn)
+	default:
+		return NetappFilerClient{}, fmt.Errorf("filer %s: unknown backend %q", f.Name, f.Backend)
+	}
 	return NetappFilerClient{
-		NetappFiler:  f,
-		NetappClient: newNetappClient(f.Host, f.Username, f.Password),
+		NetappFiler: f,
+		conn:        conn,
+	}, nil
+}
+
+// NetappClient returns the current client of the filer.
+func (f *NetappFilerClient) NetappClient() *netapp.Client {
+	return f.conn.netappClient()
+}
+
+func (c *filerConn) netappClient() *netapp.Client {
+	c.Lock()
+	defer c.Unlock()
+	return c.client
+}
+
+func (c *filerConn) credentials() (username, password string) {
+	c.Lock()
+	defer c.Unlock()
+	return c.username, c.password
+}
+
+// newClient creates a client with the given credentials. host and transport are not changed after the
+// filerConn has been created, so c does not need to be locked.
+func (c *filerConn) newClient(username, password string) (*netapp.Client, error) {
+	client := newNetappClient(c.host, username, password)
+	if c.transport != nil {
+		if err := setTransport(client, c.transport); err != nil {
+			return nil, err
+		}
 	}
+	return client, nil
 }
 
 func newNetappClient(host, username, password string) *netapp.Client {
@@ -43,28 +190,507 @@ func newNetappClient(host, username, password string) *netapp.Client {
 	return netapp.NewClient(url, version, opts)
 }
 
-func (f *NetappFilerClient) QueryAggregates(opts *netapp.AggrOptions) (res []netapp.AggrInfo, err error) {
+// defaultTransport is the transport of filers without tls config. Like go-netapp does, certificates are not
+// verified.
+func defaultTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+}
+
+func newTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: !c.Verify,
+		ServerName:         c.ServerName,
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// setTransport replaces the transport of the http client of c. go-netapp does not export the http client and
+// only configures whether certificates are verified. The field is checked before it is written, so that a
+// changed go-netapp fails with an error instead of corrupting memory.
+func setTransport(c *netapp.Client, t http.RoundTripper) error {
+	field := reflect.ValueOf(c).Elem().FieldByName("client")
+	if !field.IsValid() || field.Type() != reflect.TypeOf(&http.Client{}) {
+		return fmt.Errorf("cannot set transport: go-netapp client has no field client of type *http.Client")
+	}
+	if field.IsNil() {
+		return fmt.Errorf("cannot set transport: http client of go-netapp client is nil")
+	}
+	httpClient := (*http.Client)(unsafe.Pointer(field.Pointer()))
+	httpClient.Transport = t
+	return nil
+}
+
+// backend serves the queries that are implemented for both ZAPI and the ONTAP REST API.
+type backend interface {
+	QueryAggregates(ctx context.Context, opts *netapp.AggrOptions) ([]netapp.AggrInfo, error)
+	QueryVolumes(ctx context.Context, opts *netapp.VolumeOptions) ([]netapp.VolumeInfo, error)
+}
+
+// Backends of the filers, selected with backend in netapp_filers.yaml
+const (
+	backendZAPI = "zapi"
+	backendREST = "rest"
+)
+
+func (f *NetappFilerClient) QueryAggregates(ctx context.Context, opts *netapp.AggrOptions) ([]netapp.AggrInfo, error) {
+	return f.conn.backend.QueryAggregates(ctx, opts)
+}
+
+func (f *NetappFilerClient) QueryVolumes(ctx context.Context, opts *netapp.VolumeOptions) ([]netapp.VolumeInfo, error) {
+	return f.conn.backend.QueryVolumes(ctx, opts)
+}
+
+type zapiBackend struct {
+	conn *filerConn
+}
+
+// The Query functions stop paging when ctx is done. A request in flight is not aborted, it is limited by the
+// timeout of the client.
+func (b zapiBackend) QueryAggregates(ctx context.Context, opts *netapp.AggrOptions) (res []netapp.AggrInfo, err error) {
 	pageHandler := func(r netapp.AggrListPagesResponse) bool {
 		if r.Error != nil {
 			err = r.Error
 			return false
 		}
+		if err = resultError("aggr-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
 		res = append(res, r.Response.Results.AggrAttributes...)
-		return true
+		err = ctx.Err()
+		return err == nil
 	}
-	f.NetappClient.Aggregate.ListPages(opts, pageHandler)
+	b.conn.netappClient().Aggregate.ListPages(opts, pageHandler)
 	return
 }
 
-func (f *NetappFilerClient) QueryVolumes(opts *netapp.VolumeOptions) (res []netapp.VolumeInfo, err error) {
+func (b zapiBackend) QueryVolumes(ctx context.Context, opts *netapp.VolumeOptions) (res []netapp.VolumeInfo, err error) {
 	pageHandler := func(r netapp.VolumeListPagesResponse) bool {
 		if r.Error != nil {
 			err = r.Error
 			return false
 		}
+		if err = resultError("volume-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
 		res = append(res, r.Response.Results.AttributesList...)
-		return true
+		err = ctx.Err()
+		return err == nil
+	}
+	b.conn.netappClient().Volume.ListPages(opts, pageHandler)
+	return
+}
+
+func (f *NetappFilerClient) QuerySnapshots(ctx context.Context, opts *netapp.SnapshotOptions) (res []netapp.SnapshotInfo, err error) {
+	pageHandler := func(r netapp.SnapshotListPagesResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("snapshot-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.SnapshotAttributes...)
+		err = ctx.Err()
+		return err == nil
+	}
+	f.NetappClient().Snapshot.ListPages(opts, pageHandler)
+	return
+}
+
+func (f *NetappFilerClient) QueryQuotaReport(ctx context.Context, opts *netapp.QuotaReportOptions) (res []netapp.QuotaReportEntry, err error) {
+	pageHandler := func(r netapp.QuotaReportPagesResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("quota-report-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.QuotaReportEntry...)
+		err = ctx.Err()
+		return err == nil
+	}
+	f.NetappClient().QuotaReport.ReportPages(opts, pageHandler)
+	return
+}
+
+func (f *NetappFilerClient) QueryLuns(ctx context.Context, opts *netapp.LunOptions) (res []netapp.LunInfo, err error) {
+	pageHandler := func(r netapp.LunListPagesResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("lun-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.LunAttributes...)
+		err = ctx.Err()
+		return err == nil
+	}
+	f.NetappClient().Lun.ListPages(opts, pageHandler)
+	return
+}
+
+// zapiRequest is the request body of ONTAP API calls that go-netapp does not implement. Params must be a
+// struct whose XMLName is the name of the API.
+type zapiRequest struct {
+	netapp.Base
+	Params interface{}
+}
+
+// zapiError is a failed ZAPI result.
+type zapiError struct {
+	API    string
+	Errno  int
+	Reason string
+}
+
+func (e *zapiError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.API, e.Reason)
+}
+
+// resultError returns the error of a failed result of api, so that it is not mistaken for an empty result.
+func resultError(api string, r netapp.ResultBase) error {
+	if r.Passed() {
+		return nil
+	}
+	return &zapiError{API: api, Errno: r.ErrorNo, Reason: r.Reason}
+}
+
+// call does not abort a request in flight, since go-netapp does not take a context. Paging loops are stopped
+// before the next request instead, when ctx is done.
+func (f *NetappFilerClient) call(ctx context.Context, params interface{}, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client := f.NetappClient()
+	req, err := client.NewRequest("POST", &zapiRequest{Base: client.Snapmirror.Base, Params: params})
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(req, v)
+	return err
+}
+
+type snapmirrorGetIterOptions struct {
+	XMLName           xml.Name               `xml:"snapmirror-get-iter"`
+	DesiredAttributes *netapp.SnapmirrorInfo `xml:"desired-attributes>snapmirror-info,omitempty"`
+	MaxRecords        int                    `xml:"max-records,omitempty"`
+	Tag               string                 `xml:"tag,omitempty"`
+}
+
+// snapmirrorInfo decodes the fields of netapp.SnapmirrorInfo that ONTAP omits for relationships that have
+// never transferred as pointers, so that they are not mistaken for 0.
+type snapmirrorInfo struct {
+	netapp.SnapmirrorInfo
+	LagTime              *int `xml:"lag-time"`
+	LastTransferDuration *int `xml:"last-transfer-duration"`
+	LastTransferSize     *int `xml:"last-transfer-size"`
+}
+
+type snapmirrorGetIterResponse struct {
+	XMLName xml.Name `xml:"netapp"`
+	Results struct {
+		netapp.ResultBase
+		AttributesList []snapmirrorInfo `xml:"attributes-list>snapmirror-info"`
+		NextTag        string           `xml:"next-tag"`
+	} `xml:"results"`
+}
+
+func (f *NetappFilerClient) QuerySnapmirrors(ctx context.Context, opts *snapmirrorGetIterOptions) (res []snapmirrorInfo, err error) {
+	for {
+		r := snapmirrorGetIterResponse{}
+		if err = f.call(ctx, opts, &r); err != nil {
+			return
+		}
+		if err = resultError("snapmirror-get-iter", r.Results.ResultBase); err != nil {
+			return
+		}
+		res = append(res, r.Results.AttributesList...)
+		if r.Results.NextTag == "" {
+			return
+		}
+		opts = &snapmirrorGetIterOptions{
+			DesiredAttributes: opts.DesiredAttributes,
+			MaxRecords:        opts.MaxRecords,
+			Tag:               r.Results.NextTag,
+		}
+	}
+}
+
+type systemNodeGetIterOptions struct {
+	XMLName    xml.Name `xml:"system-node-get-iter"`
+	MaxRecords int      `xml:"max-records,omitempty"`
+	Tag        string   `xml:"tag,omitempty"`
+}
+
+// nodeDetailsInfo adds the fields to netapp.NodeDetails that go-netapp does not decode.
+type nodeDetailsInfo struct {
+	netapp.NodeDetails
+	IsNodeHealthy bool `xml:"is-node-healthy"`
+}
+
+type systemNodeGetIterResponse struct {
+	XMLName xml.Name `xml:"netapp"`
+	Results struct {
+		netapp.ResultBase
+		AttributesList []nodeDetailsInfo `xml:"attributes-list>node-details-info"`
+		NextTag        string            `xml:"next-tag"`
+	} `xml:"results"`
+}
+
+func (f *NetappFilerClient) QueryNodes(ctx context.Context, opts *systemNodeGetIterOptions) (res []nodeDetailsInfo, err error) {
+	for {
+		r := systemNodeGetIterResponse{}
+		if err = f.call(ctx, opts, &r); err != nil {
+			return
+		}
+		if err = resultError("system-node-get-iter", r.Results.ResultBase); err != nil {
+			return
+		}
+		res = append(res, r.Results.AttributesList...)
+		if r.Results.NextTag == "" {
+			return
+		}
+		opts = &systemNodeGetIterOptions{
+			MaxRecords: opts.MaxRecords,
+			Tag:        r.Results.NextTag,
+		}
+	}
+}
+
+func (f *NetappFilerClient) QueryStorageFailover(ctx context.Context, opts *netapp.ClusterFailoverInfoOptions) (res []netapp.StorageFailoverInfo, err error) {
+	pageHandler := func(r netapp.ClusterFailoverInfoPagesResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("cf-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.StorageFailoverInfo...)
+		err = ctx.Err()
+		return err == nil
+	}
+	f.NetappClient().Cf.ClusterFailoverInfoListPages(opts, pageHandler)
+	return
+}
+
+type storageDiskGetIterOptions struct {
+	XMLName    xml.Name `xml:"storage-disk-get-iter"`
+	MaxRecords int      `xml:"max-records,omitempty"`
+	Tag        string   `xml:"tag,omitempty"`
+}
+
+// storageDiskInfo adds the raid information to netapp.StorageDiskInfo, which go-netapp does not decode.
+type storageDiskInfo struct {
+	netapp.StorageDiskInfo
+	DiskRaidInfo struct {
+		ContainerType     string `xml:"container-type"`
+		UsedBlocks        int    `xml:"used-blocks"`
+		IsZeroing         bool   `xml:"disk-spare-info>is-zeroing"`
+		DiskAggregateInfo *struct {
+			AggregateName    string `xml:"aggregate-name"`
+			IsOffline        bool   `xml:"is-offline"`
+			IsPrefailed      bool   `xml:"is-prefailed"`
+			IsReconstructing bool   `xml:"is-reconstructing"`
+		} `xml:"disk-aggregate-info"`
+	} `xml:"disk-raid-info"`
+}
+
+type storageDiskGetIterResponse struct {
+	XMLName xml.Name `xml:"netapp"`
+	Results struct {
+		netapp.ResultBase
+		AttributesList []storageDiskInfo `xml:"attributes-list>storage-disk-info"`
+		NextTag        string            `xml:"next-tag"`
+	} `xml:"results"`
+}
+
+func (f *NetappFilerClient) QueryDisks(ctx context.Context, opts *storageDiskGetIterOptions) (res []storageDiskInfo, err error) {
+	for {
+		r := storageDiskGetIterResponse{}
+		if err = f.call(ctx, opts, &r); err != nil {
+			return
+		}
+		if err = resultError("storage-disk-get-iter", r.Results.ResultBase); err != nil {
+			return
+		}
+		res = append(res, r.Results.AttributesList...)
+		if r.Results.NextTag == "" {
+			return
+		}
+		opts = &storageDiskGetIterOptions{
+			MaxRecords: opts.MaxRecords,
+			Tag:        r.Results.NextTag,
+		}
+	}
+}
+
+type perfObjectInstanceListInfoIterOptions struct {
+	XMLName    xml.Name `xml:"perf-object-instance-list-info-iter"`
+	ObjectName string   `xml:"objectname"`
+	MaxRecords int      `xml:"max-records,omitempty"`
+	Tag        string   `xml:"tag,omitempty"`
+}
+
+type perfObjectGetInstancesOptions struct {
+	XMLName       xml.Name `xml:"perf-object-get-instances"`
+	ObjectName    string   `xml:"objectname"`
+	InstanceUuids []string `xml:"instance-uuids>instance-uuid"`
+	Counters      []string `xml:"counters>counter"`
+}
+
+type perfInstanceData struct {
+	Name     string                   `xml:"name"`
+	Uuid     string                   `xml:"uuid"`
+	Counters []netapp.PerfCounterData `xml:"counters>counter-data"`
+}
+
+type perfObjectGetInstancesResponse struct {
+	XMLName xml.Name `xml:"netapp"`
+	Results struct {
+		netapp.ResultBase
+		Instances []perfInstanceData `xml:"instances>instance-data"`
+	} `xml:"results"`
+}
+
+// QueryPerfInstances returns the given counters of all instances of a perf object. The instances are
+// listed first and then fetched in batches of opts.MaxRecords.
+func (f *NetappFilerClient) QueryPerfInstances(ctx context.Context, opts *perfObjectInstanceListInfoIterOptions, counters []string) (res []perfInstanceData, err error) {
+	var uuids []string
+	for {
+		r := netapp.PerfObjectInstanceListInfoIterResponse{}
+		if err = f.call(ctx, opts, &r); err != nil {
+			return
+		}
+		if err = resultError("perf-object-instance-list-info-iter", r.Results.ResultBase); err != nil {
+			return
+		}
+		for _, i := range r.Results.AttributesList.InstanceInfo {
+			uuids = append(uuids, i.Uuid)
+		}
+		if r.Results.NextTag == "" {
+			break
+		}
+		opts = &perfObjectInstanceListInfoIterOptions{
+			ObjectName: opts.ObjectName,
+			MaxRecords: opts.MaxRecords,
+			Tag:        r.Results.NextTag,
+		}
+	}
+
+	for len(uuids) > 0 {
+		n := opts.MaxRecords
+		if n <= 0 || n > len(uuids) {
+			n = len(uuids)
+		}
+		r := perfObjectGetInstancesResponse{}
+		params := &perfObjectGetInstancesOptions{
+			ObjectName:    opts.ObjectName,
+			InstanceUuids: uuids[:n],
+			Counters:      counters,
+		}
+		if err = f.call(ctx, params, &r); err != nil {
+			return
+		}
+		if err = resultError("perf-object-get-instances", r.Results.ResultBase); err != nil {
+			return
+		}
+		res = append(res, r.Results.Instances...)
+		uuids = uuids[n:]
+	}
+	return
+}
+
+func (f *NetappFilerClient) QueryNetInterfaces(ctx context.Context, opts *netapp.NetInterfaceOptions) (res []netapp.NetInterfaceInfo, err error) {
+	pageHandler := func(r netapp.NetInterfacePageResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("net-interface-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.NetInterfaceAttributes...)
+		err = ctx.Err()
+		return err == nil
+	}
+	f.NetappClient().Net.NetInterfaceGetAll(opts, pageHandler)
+	return
+}
+
+func (f *NetappFilerClient) QueryNetPorts(ctx context.Context, opts *netapp.NetPortOptions) (res []netapp.NetPortInfo, err error) {
+	pageHandler := func(r netapp.NetPortPageResponse) bool {
+		if r.Error != nil {
+			err = r.Error
+			return false
+		}
+		if err = resultError("net-port-get-iter", r.Response.Results.ResultBase); err != nil {
+			return false
+		}
+		res = append(res, r.Response.Results.AttributesList.NetPortAttributes...)
+		err = ctx.Err()
+		return err == nil
 	}
-	f.NetappClient.Volume.ListPages(opts, pageHandler)
+	f.NetappClient().Net.NetPortGetAll(opts, pageHandler)
 	return
 }
+
+type vserverGetIterOptions struct {
+	XMLName    xml.Name             `xml:"vserver-get-iter"`
+	MaxRecords int                  `xml:"max-records,omitempty"`
+	Query      *netapp.VServerQuery `xml:"query,omitempty"`
+	Tag        string               `xml:"tag,omitempty"`
+}
+
+type vserverGetIterResponse struct {
+	XMLName xml.Name `xml:"netapp"`
+	Results struct {
+		netapp.ResultBase
+		AttributesList []netapp.VServerInfo `xml:"attributes-list>vserver-info"`
+		NextTag        string               `xml:"next-tag"`
+	} `xml:"results"`
+}
+
+// QueryVservers pages vserver-get-iter, which go-netapp only implements for the first page.
+func (f *NetappFilerClient) QueryVservers(ctx context.Context, opts *vserverGetIterOptions) (res []netapp.VServerInfo, err error) {
+	for {
+		r := vserverGetIterResponse{}
+		if err = f.call(ctx, opts, &r); err != nil {
+			return
+		}
+		if err = resultError("vserver-get-iter", r.Results.ResultBase); err != nil {
+			return
+		}
+		res = append(res, r.Results.AttributesList...)
+		if r.Results.NextTag == "" {
+			return
+		}
+		opts = &vserverGetIterOptions{
+			MaxRecords: opts.MaxRecords,
+			Query:      opts.Query,
+			Tag:        r.Results.NextTag,
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetappClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<netapp><results status="passed"><num-records>0</num-records></results></netapp>`))
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	assert.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	query := func(c *TLSConfig) error {
+		f, err := NewNetappClient(NetappFiler{Name: "filer", Host: host, Username: "user", Password: "secret", TLS: c}, ClientOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = f.QueryNodes(context.Background(), &systemNodeGetIterOptions{})
+		return err
+	}
+
+	assert.NoError(t, query(nil))
+	assert.NoError(t, query(&TLSConfig{}))
+	assert.Error(t, query(&TLSConfig{Verify: true}))
+	assert.NoError(t, query(&TLSConfig{Verify: true, CAFile: caFile.Name()}))
+	assert.Error(t, query(&TLSConfig{Verify: true, CAFile: caFile.Name(), ServerName: "other.example"}))
+	assert.NoError(t, query(&TLSConfig{Verify: true, CAFile: caFile.Name(), ServerName: "example.com"}))
+	assert.Error(t, query(&TLSConfig{CAFile: "/nonexistent"}))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestSetTransport fails when go-netapp changes its http client field.
+func TestSetTransport(t *testing.T) {
+	c := newNetappClient("filer.example.com", "user", "secret")
+	var called bool
+	assert.NoError(t, setTransport(c, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return nil, context.Canceled
+	})))
+	req, err := c.NewRequest("POST", &zapiRequest{Base: c.Snapmirror.Base, Params: &vserverGetIterOptions{}})
+	assert.NoError(t, err)
+	c.Do(req, nil)
+	assert.True(t, called)
+}
+
+// A failed ZAPI result is an error, so that it is not mistaken for an empty result.
+func TestQueryFailedResult(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+	filer := newFakeFiler(t, s)
+	ctx := context.Background()
+
+	s.SetError("volume-get-iter", 13003, "insufficient privileges")
+	_, err := filer.QueryVolumes(ctx, &netapp.VolumeOptions{MaxRecords: 20})
+	assert.EqualError(t, err, "volume-get-iter failed: insufficient privileges")
+
+	s.SetError("aggr-get-iter", 13003, "insufficient privileges")
+	_, err = filer.QueryAggregates(ctx, &netapp.AggrOptions{MaxRecords: 20})
+	assert.EqualError(t, err, "aggr-get-iter failed: insufficient privileges")
+
+	// the fake filer does not implement snapshot-get-iter
+	_, err = filer.QuerySnapshots(ctx, &netapp.SnapshotOptions{MaxRecords: 20})
+	assert.EqualError(t, err, "snapshot-get-iter failed: Unable to find API: snapshot-get-iter")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappDisk struct {
+	FilerName string
+	Name      string
+	Node      string
+	Aggregate string
+	Type      string
+	Rpm       float64
+	Capacity  float64
+	Used      float64
+	Broken    bool
+	Spare     bool
+	Zeroing   bool
+	// Degraded is set for disks of an aggregate that are offline, prefailed or being reconstructed.
+	Degraded bool
+}
+
+type diskMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(disk *NetappDisk) float64
+}
+
+var (
+	diskLabels = []string{
+		"node",
+		"disk",
+		"aggregate",
+		"type",
+	}
+
+	dMetrics = diskMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_disk_present",
+				"Netapp Disk Metrics: disk is present",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return 1 },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_broken",
+				"Netapp Disk Metrics: disk is broken",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return boolToFloat(d.Broken) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_spare",
+				"Netapp Disk Metrics: disk is a spare",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return boolToFloat(d.Spare) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_zeroing",
+				"Netapp Disk Metrics: disk is being zeroed",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return boolToFloat(d.Zeroing) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_capacity_bytes",
+				"Netapp Disk Metrics: physical capacity",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return d.Capacity },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_used_bytes",
+				"Netapp Disk Metrics: size used by raid",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return d.Used },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_disk_rpm",
+				"Netapp Disk Metrics: rated rotational speed (0: solid state)",
+				diskLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(d *NetappDisk) float64 { return d.Rpm },
+		},
+	}
+
+	// Spare and broken disks do not belong to any aggregate, so they are counted per node owning them.
+	nodeSpareDisksDesc = prometheus.NewDesc(
+		"netapp_node_spare_disks",
+		"Netapp Node Metrics: number of spare disks owned by the node",
+		[]string{"node"},
+		nil)
+	nodeBrokenDisksDesc = prometheus.NewDesc(
+		"netapp_node_broken_disks",
+		"Netapp Node Metrics: number of broken disks owned by the node",
+		[]string{"node"},
+		nil)
+	aggrDegradedDisksDesc = prometheus.NewDesc(
+		"netapp_aggregate_degraded_disks",
+		"Netapp Aggregate Metrics: number of disks of the aggregate that are offline, prefailed or being reconstructed",
+		aggregateLabels,
+		nil)
+)
+
+type DiskCollector struct {
+	ApiCollectorBase
+	Filer NetappFilerClient
+	Disks []*NetappDisk
+}
+
+func (d *DiskCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range dMetrics {
+		ch <- v.desc
+	}
+	ch <- nodeSpareDisksDesc
+	ch <- nodeBrokenDisksDesc
+	ch <- aggrDegradedDisksDesc
+}
+
+func (d *DiskCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range d.Disks {
+		labels := []string{v.Node, v.Name, v.Aggregate, v.Type}
+		for _, m := range dMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+	}
+
+	for _, n := range countDisksByNode(d.Disks) {
+		ch <- prometheus.MustNewConstMetric(nodeSpareDisksDesc, prometheus.GaugeValue, float64(n.spare), n.node)
+		ch <- prometheus.MustNewConstMetric(nodeBrokenDisksDesc, prometheus.GaugeValue, float64(n.broken), n.node)
+	}
+	for _, a := range countDisksByAggregate(d.Disks) {
+		ch <- prometheus.MustNewConstMetric(aggrDegradedDisksDesc, prometheus.GaugeValue, float64(a.degraded), a.node, a.aggregate)
+	}
+}
+
+type nodeDiskCount struct {
+	node   string
+	spare  int
+	broken int
+}
+
+func countDisksByNode(disks []*NetappDisk) (res []*nodeDiskCount) {
+	counts := make(map[string]*nodeDiskCount)
+	for _, d := range disks {
+		c, ok := counts[d.Node]
+		if !ok {
+			c = &nodeDiskCount{node: d.Node}
+			counts[d.Node] = c
+			res = append(res, c)
+		}
+		if d.Spare {
+			c.spare++
+		}
+		if d.Broken {
+			c.broken++
+		}
+	}
+	return
+}
+
+type aggregateDiskCount struct {
+	node      string
+	aggregate string
+	degraded  int
+}
+
+// countDisksByAggregate counts the degraded disks of every aggregate. Only the disks that belong to the
+// aggregate are counted.
+func countDisksByAggregate(disks []*NetappDisk) (res []*aggregateDiskCount) {
+	counts := make(map[string]*aggregateDiskCount)
+	for _, d := range disks {
+		if d.Aggregate == "" {
+			continue
+		}
+		c, ok := counts[d.Node+"/"+d.Aggregate]
+		if !ok {
+			c = &aggregateDiskCount{node: d.Node, aggregate: d.Aggregate}
+			counts[d.Node+"/"+d.Aggregate] = c
+			res = append(res, c)
+		}
+		if d.Degraded {
+			c.degraded++
+		}
+	}
+	return
+}
+
+func (d *DiskCollector) SaveData(data []interface{}) error {
+	disks := make([]*NetappDisk, 0)
+	for _, v := range data {
+		if disk, ok := v.(*NetappDisk); ok {
+			disks = append(disks, disk)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappDisk")
+		}
+	}
+	d.Disks = disks
+	return nil
+}
+
+func (d *DiskCollector) Fetch(ctx context.Context) (disks []interface{}, err error) {
+	res, err := d.Filer.QueryDisks(ctx, &storageDiskGetIterOptions{MaxRecords: 100})
+
+	if err == nil {
+		logger.Printf("%s: %d disks fetched", d.Filer.Host, len(res))
+		disks = make([]interface{}, 0)
+		for _, n := range res {
+			nd := &NetappDisk{
+				FilerName: d.Filer.Name,
+				Name:      n.DiskName,
+				// used blocks are counted in 4KB blocks
+				Used:    float64(n.DiskRaidInfo.UsedBlocks) * 4096,
+				Broken:  n.DiskRaidInfo.ContainerType == "broken",
+				Spare:   n.DiskRaidInfo.ContainerType == "spare",
+				Zeroing: n.DiskRaidInfo.IsZeroing,
+			}
+			if a := n.DiskRaidInfo.DiskAggregateInfo; a != nil {
+				nd.Aggregate = a.AggregateName
+				nd.Degraded = a.IsOffline || a.IsPrefailed || a.IsReconstructing
+			}
+			if i := n.DiskInventoryInfo; i != nil {
+				nd.Type = i.DiskType
+				nd.Rpm = float64(i.Rpm)
+				nd.Capacity = float64(i.CapacitySectors) * float64(i.BytesPerSector)
+			}
+			if o := n.DiskOwnershipInfo; o != nil {
+				nd.Node = o.OwnerNodeName
+				if o.IsFailed != nil && *o.IsFailed {
+					nd.Broken = true
+				}
+			}
+			disks = append(disks, nd)
+		}
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountDisks(t *testing.T) {
+	disks := []*NetappDisk{
+		{Name: "1.0.0", Node: "node1", Aggregate: "aggr1"},
+		{Name: "1.0.1", Node: "node1", Aggregate: "aggr1", Degraded: true},
+		{Name: "1.0.2", Node: "node1", Aggregate: "aggr2"},
+		{Name: "1.0.3", Node: "node1", Spare: true},
+		{Name: "1.0.4", Node: "node1", Spare: true},
+		{Name: "2.0.0", Node: "node2", Aggregate: "aggr3"},
+		{Name: "2.0.1", Node: "node2", Broken: true},
+	}
+
+	// spares are counted once per node, not per aggregate of the node
+	assert.Equal(t, []*nodeDiskCount{
+		{node: "node1", spare: 2, broken: 0},
+		{node: "node2", spare: 0, broken: 1},
+	}, countDisksByNode(disks))
+
+	assert.Equal(t, []*aggregateDiskCount{
+		{node: "node1", aggregate: "aggr1", degraded: 1},
+		{node: "node1", aggregate: "aggr2", degraded: 0},
+		{node: "node2", aggregate: "aggr3", degraded: 0},
+	}, countDisksByAggregate(disks))
+}
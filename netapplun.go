@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappLun struct {
+	FilerName                 string
+	Vserver                   string
+	Volume                    string
+	Path                      string
+	Size                      float64
+	SizeUsed                  float64
+	Online                    bool
+	Mapped                    bool
+	IsSpaceReservationEnabled bool
+	IsSpaceAllocEnabled       bool
+}
+
+type lunMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(lun *NetappLun) float64
+}
+
+var (
+	lunLabels = []string{
+		"vserver",
+		"volume",
+		"lun",
+	}
+
+	lMetrics = lunMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_lun_total_bytes",
+				"Netapp Lun Metrics: total size",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return l.Size },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lun_used_bytes",
+				"Netapp Lun Metrics: used size",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return l.SizeUsed },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lun_online",
+				"Netapp Lun Metrics: lun is online",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return boolToFloat(l.Online) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lun_mapped",
+				"Netapp Lun Metrics: lun is mapped to an igroup",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return boolToFloat(l.Mapped) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lun_space_reservation_enabled",
+				"Netapp Lun Metrics: space reservation is enabled",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return boolToFloat(l.IsSpaceReservationEnabled) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lun_space_alloc_enabled",
+				"Netapp Lun Metrics: space allocation is enabled",
+				lunLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLun) float64 { return boolToFloat(l.IsSpaceAllocEnabled) },
+		},
+	}
+)
+
+type LunCollector struct {
+	ApiCollectorBase
+	Filer NetappFilerClient
+	Luns  []*NetappLun
+}
+
+func (l *LunCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range lMetrics {
+		ch <- v.desc
+	}
+}
+
+func (l *LunCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range l.Luns {
+		labels := []string{v.Vserver, v.Volume, v.Path}
+		for _, m := range lMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+	}
+}
+
+func (l *LunCollector) SaveData(data []interface{}) error {
+	luns := make([]*NetappLun, 0)
+	for _, d := range data {
+		if lun, ok := d.(*NetappLun); ok {
+			luns = append(luns, lun)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappLun")
+		}
+	}
+	l.Luns = luns
+	return nil
+}
+
+func (l *LunCollector) Fetch(ctx context.Context) (luns []interface{}, err error) {
+	opts := &netapp.LunOptions{
+		MaxRecords: 100,
+	}
+
+	res, err := l.Filer.QueryLuns(ctx, opts)
+
+	if err == nil {
+		logger.Printf("%s: %d luns fetched", l.Filer.Host, len(res))
+		luns = make([]interface{}, 0)
+		for _, n := range res {
+			luns = append(luns, newNetappLun(l.Filer.Name, n))
+		}
+	}
+	return
+}
+
+func newNetappLun(filerName string, n netapp.LunInfo) *NetappLun {
+	return &NetappLun{
+		FilerName:                 filerName,
+		Vserver:                   n.Vserver,
+		Volume:                    n.Volume,
+		Path:                      n.Path,
+		Size:                      float64(n.Size),
+		SizeUsed:                  float64(n.SizeUsed),
+		Online:                    n.Online,
+		Mapped:                    n.Mapped,
+		IsSpaceReservationEnabled: n.IsSpaceReservationEnabled,
+		IsSpaceAllocEnabled:       n.IsSpaceAllocEnabled,
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
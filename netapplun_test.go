@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLunCollectorCollect(t *testing.T) {
+	var n netapp.LunInfo
+	assert.NoError(t, xml.Unmarshal([]byte(`<lun-info>
+  <vserver>vs1</vserver>
+  <volume>vol1</volume>
+  <path>/vol/vol1/lun1</path>
+  <size>10737418240</size>
+  <size-used>1073741824</size-used>
+  <online>true</online>
+  <mapped>false</mapped>
+  <is-space-reservation-enabled>true</is-space-reservation-enabled>
+  <is-space-alloc-enabled>false</is-space-alloc-enabled>
+</lun-info>`), &n))
+	c := &LunCollector{Luns: []*NetappLun{newNetappLun("filer", n)}}
+
+	expected := `
+# HELP netapp_lun_mapped Netapp Lun Metrics: lun is mapped to an igroup
+# TYPE netapp_lun_mapped gauge
+netapp_lun_mapped{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 0
+# HELP netapp_lun_online Netapp Lun Metrics: lun is online
+# TYPE netapp_lun_online gauge
+netapp_lun_online{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 1
+# HELP netapp_lun_space_alloc_enabled Netapp Lun Metrics: space allocation is enabled
+# TYPE netapp_lun_space_alloc_enabled gauge
+netapp_lun_space_alloc_enabled{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 0
+# HELP netapp_lun_space_reservation_enabled Netapp Lun Metrics: space reservation is enabled
+# TYPE netapp_lun_space_reservation_enabled gauge
+netapp_lun_space_reservation_enabled{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 1
+# HELP netapp_lun_total_bytes Netapp Lun Metrics: total size
+# TYPE netapp_lun_total_bytes gauge
+netapp_lun_total_bytes{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 1.073741824e+10
+# HELP netapp_lun_used_bytes Netapp Lun Metrics: used size
+# TYPE netapp_lun_used_bytes gauge
+netapp_lun_used_bytes{lun="/vol/vol1/lun1",volume="vol1",vserver="vs1"} 1.073741824e+09
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappLif struct {
+	FilerName   string
+	Vserver     string
+	Name        string
+	CurrentNode string
+	CurrentPort string
+	HomeNode    string
+	HomePort    string
+	OperUp      bool
+	AdminUp     bool
+	IsHome      bool
+}
+
+type NetappPort struct {
+	FilerName string
+	Node      string
+	Port      string
+	LinkUp    bool
+	Speed     float64
+}
+
+type lifMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(lif *NetappLif) float64
+}
+
+type portMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(port *NetappPort) float64
+}
+
+var (
+	// The placement of a lif is only exported by netapp_lif_info, so that the series of the other metrics
+	// do not change when the lif migrates.
+	lifLabels = []string{
+		"vserver",
+		"lif",
+	}
+
+	lifInfoDesc = prometheus.NewDesc(
+		"netapp_lif_info",
+		"Netapp Network Metrics: current and home node and port of the lif",
+		[]string{"vserver", "lif", "node", "port", "home_node", "home_port"},
+		nil)
+
+	portLabels = []string{
+		"node",
+		"port",
+	}
+
+	lfMetrics = lifMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_lif_operational_up",
+				"Netapp Network Metrics: lif operational status is up",
+				lifLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLif) float64 { return boolToFloat(l.OperUp) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lif_admin_up",
+				"Netapp Network Metrics: lif administrative status is up",
+				lifLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLif) float64 { return boolToFloat(l.AdminUp) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_lif_is_home",
+				"Netapp Network Metrics: lif is on its home node and port",
+				lifLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(l *NetappLif) float64 { return boolToFloat(l.IsHome) },
+		},
+	}
+
+	pMetrics = portMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_port_link_up",
+				"Netapp Network Metrics: port link status is up",
+				portLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappPort) float64 { return boolToFloat(p.LinkUp) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_port_speed_bytes_per_second",
+				"Netapp Network Metrics: operational speed in bytes per second",
+				portLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappPort) float64 { return p.Speed },
+		},
+	}
+)
+
+type NetworkCollector struct {
+	ApiCollectorBase
+	Filer NetappFilerClient
+	Lifs  []*NetappLif
+	Ports []*NetappPort
+}
+
+func (n *NetworkCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range lfMetrics {
+		ch <- v.desc
+	}
+	ch <- lifInfoDesc
+	for _, v := range pMetrics {
+		ch <- v.desc
+	}
+}
+
+func (n *NetworkCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range n.Lifs {
+		labels := []string{v.Vserver, v.Name}
+		for _, m := range lfMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(lifInfoDesc, prometheus.GaugeValue, 1,
+			v.Vserver, v.Name, v.CurrentNode, v.CurrentPort, v.HomeNode, v.HomePort)
+	}
+	for _, v := range n.Ports {
+		labels := []string{v.Node, v.Port}
+		for _, m := range pMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+	}
+}
+
+func (n *NetworkCollector) SaveData(data []interface{}) error {
+	lifs := make([]*NetappLif, 0)
+	ports := make([]*NetappPort, 0)
+	for _, d := range data {
+		switch v := d.(type) {
+		case *NetappLif:
+			lifs = append(lifs, v)
+		case *NetappPort:
+			ports = append(ports, v)
+		default:
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappLif or []*NetappPort")
+		}
+	}
+	n.Lifs = lifs
+	n.Ports = ports
+	return nil
+}
+
+func (n *NetworkCollector) Fetch(ctx context.Context) (data []interface{}, err error) {
+	lifs, err := n.Filer.QueryNetInterfaces(ctx, &netapp.NetInterfaceOptions{MaxRecords: 100})
+	if err != nil {
+		return
+	}
+	ports, err := n.Filer.QueryNetPorts(ctx, &netapp.NetPortOptions{MaxRecords: 100})
+	if err != nil {
+		return
+	}
+	logger.Printf("%s: %d lifs and %d ports fetched", n.Filer.Host, len(lifs), len(ports))
+
+	data = make([]interface{}, 0)
+	for _, l := range lifs {
+		data = append(data, &NetappLif{
+			FilerName:   n.Filer.Name,
+			Vserver:     l.Vserver,
+			Name:        l.InterfaceName,
+			CurrentNode: l.CurrentNode,
+			CurrentPort: l.CurrentPort,
+			HomeNode:    l.HomeNode,
+			HomePort:    l.HomePort,
+			OperUp:      l.OperationalStatus == "up",
+			AdminUp:     l.AdministrativeStatus == "up",
+			IsHome:      l.IsHome,
+		})
+	}
+	for _, p := range ports {
+		// operational speed is reported in Mbit/s, or "auto" and "-" if unknown
+		speed, _ := strconv.ParseFloat(p.OperationalSpeed, 64)
+		data = append(data, &NetappPort{
+			FilerName: n.Filer.Name,
+			Node:      p.Node,
+			Port:      p.Port,
+			LinkUp:    p.LinkStatus == "up",
+			Speed:     speed * 1e6 / 8,
+		})
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkCollectorCollect(t *testing.T) {
+	c := &NetworkCollector{
+		Lifs: []*NetappLif{{
+			Vserver:     "vs1",
+			Name:        "lif1",
+			CurrentNode: "node2",
+			CurrentPort: "e0a",
+			HomeNode:    "node1",
+			HomePort:    "e0a",
+			OperUp:      true,
+			AdminUp:     true,
+		}},
+	}
+
+	// the placement of the lif is only a label of netapp_lif_info
+	expected := `
+# HELP netapp_lif_info Netapp Network Metrics: current and home node and port of the lif
+# TYPE netapp_lif_info gauge
+netapp_lif_info{home_node="node1",home_port="e0a",lif="lif1",node="node2",port="e0a",vserver="vs1"} 1
+# HELP netapp_lif_is_home Netapp Network Metrics: lif is on its home node and port
+# TYPE netapp_lif_is_home gauge
+netapp_lif_is_home{lif="lif1",vserver="vs1"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "netapp_lif_info", "netapp_lif_is_home"))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappNode struct {
+	FilerName        string
+	Name             string
+	Uptime           float64
+	Healthy          bool
+	NvramBatteryOk   bool
+	HasFailover      bool
+	TakeoverPossible bool
+	Partner          string
+	FailoverState    string
+	PartnerState     string
+}
+
+type nodeMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(node *NetappNode) float64
+}
+
+var (
+	nodeLabels = []string{
+		"node",
+	}
+
+	ndMetrics = nodeMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_node_uptime_seconds",
+				"Netapp Node Metrics: uptime",
+				nodeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(n *NetappNode) float64 { return n.Uptime },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_node_healthy",
+				"Netapp Node Metrics: node is healthy",
+				nodeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(n *NetappNode) float64 { return boolToFloat(n.Healthy) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_node_nvram_battery_ok",
+				"Netapp Node Metrics: NVRAM battery status is ok",
+				nodeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(n *NetappNode) float64 { return boolToFloat(n.NvramBatteryOk) },
+		},
+	}
+
+	// failover metrics are only exported for nodes in a HA pair
+	failoverMetrics = nodeMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_node_failover_takeover_possible",
+				"Netapp Node Metrics: partner is able to take over the node",
+				nodeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(n *NetappNode) float64 { return boolToFloat(n.TakeoverPossible) },
+		},
+	}
+
+	failoverStateDesc = prometheus.NewDesc(
+		"netapp_node_failover_state_info",
+		"Netapp Node Metrics: storage failover state of the node and the firmware state of its partner",
+		[]string{"node", "partner", "state", "partner_state"},
+		nil)
+)
+
+type NodeCollector struct {
+	ApiCollectorBase
+	Filer NetappFilerClient
+	Nodes []*NetappNode
+}
+
+func (n *NodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range ndMetrics {
+		ch <- v.desc
+	}
+	for _, v := range failoverMetrics {
+		ch <- v.desc
+	}
+	ch <- failoverStateDesc
+}
+
+func (n *NodeCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range n.Nodes {
+		labels := []string{v.Name}
+		for _, m := range ndMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+		if !v.HasFailover {
+			continue
+		}
+		for _, m := range failoverMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(failoverStateDesc, prometheus.GaugeValue, 1, v.Name, v.Partner, v.FailoverState, v.PartnerState)
+	}
+}
+
+func (n *NodeCollector) SaveData(data []interface{}) error {
+	nodes := make([]*NetappNode, 0)
+	for _, d := range data {
+		if node, ok := d.(*NetappNode); ok {
+			nodes = append(nodes, node)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappNode")
+		}
+	}
+	n.Nodes = nodes
+	return nil
+}
+
+func (n *NodeCollector) Fetch(ctx context.Context) (nodes []interface{}, err error) {
+	res, err := n.Filer.QueryNodes(ctx, &systemNodeGetIterOptions{MaxRecords: 20})
+	if err != nil {
+		return
+	}
+	logger.Printf("%s: %d nodes fetched", n.Filer.Host, len(res))
+
+	// Single node clusters return no failover information, their nodes are exported without failover metrics.
+	sfo, err := n.Filer.QueryStorageFailover(ctx, &netapp.ClusterFailoverInfoOptions{MaxRecords: 20})
+	if err != nil {
+		return
+	}
+	failover := make(map[string]netapp.StorageFailoverInfo)
+	for _, s := range sfo {
+		failover[s.SfoNodeInfo.NodeRelatedInfo.Node] = s
+	}
+
+	nodes = make([]interface{}, 0)
+	for _, d := range res {
+		uptime, _ := strconv.ParseFloat(d.NodeUptime, 64)
+		nn := &NetappNode{
+			FilerName:      n.Filer.Name,
+			Name:           d.Name,
+			Uptime:         uptime,
+			Healthy:        d.IsNodeHealthy,
+			NvramBatteryOk: d.NvramBatteryStatus == "battery_ok",
+		}
+		if s, ok := failover[d.Name]; ok {
+			nn.HasFailover = true
+			nn.TakeoverPossible = s.SfoTakeoverInfo.TakeoverRelatedInfo.TakeoverByPartnerPossible
+			nn.Partner = s.SfoNodeInfo.NodeRelatedInfo.PartnerName
+			nn.FailoverState = s.SfoNodeInfo.NodeRelatedInfo.NodeState
+			nn.PartnerState = s.SfoNodeInfo.NodeRelatedInfo.PartnerFirmwareState
+		}
+		nodes = append(nodes, nn)
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeCollectorCollect(t *testing.T) {
+	c := &NodeCollector{
+		Nodes: []*NetappNode{{
+			Name:             "node1",
+			HasFailover:      true,
+			TakeoverPossible: true,
+			Partner:          "node2",
+			FailoverState:    "connected",
+			PartnerState:     "SF_UP",
+		}, {
+			// single node cluster
+			Name: "node3",
+		}},
+	}
+
+	expected := `
+# HELP netapp_node_failover_state_info Netapp Node Metrics: storage failover state of the node and the firmware state of its partner
+# TYPE netapp_node_failover_state_info gauge
+netapp_node_failover_state_info{node="node1",partner="node2",partner_state="SF_UP",state="connected"} 1
+# HELP netapp_node_failover_takeover_possible Netapp Node Metrics: partner is able to take over the node
+# TYPE netapp_node_failover_takeover_possible gauge
+netapp_node_failover_takeover_possible{node="node1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"netapp_node_failover_state_info", "netapp_node_failover_takeover_possible"))
+}
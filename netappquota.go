@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappQuota struct {
+	FilerName string
+	Vserver   string
+	Volume    string
+	Qtree     string
+	Type      string
+	Target    string
+	DiskUsed  float64
+	DiskLimit float64
+	FilesUsed float64
+	FileLimit float64
+}
+
+type quotaMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(q *NetappQuota) float64
+}
+
+var (
+	quotaLabels = []string{
+		"vserver",
+		"volume",
+		"qtree",
+		"type",
+		"target",
+	}
+
+	quoMetrics = quotaMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_quota_disk_used_bytes",
+				"Netapp Quota Metrics: disk space used",
+				quotaLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(q *NetappQuota) float64 { return q.DiskUsed },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_quota_disk_limit_bytes",
+				"Netapp Quota Metrics: disk space limit (-1: unlimited)",
+				quotaLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(q *NetappQuota) float64 { return q.DiskLimit },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_quota_files_used",
+				"Netapp Quota Metrics: number of files used",
+				quotaLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(q *NetappQuota) float64 { return q.FilesUsed },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_quota_files_limit",
+				"Netapp Quota Metrics: file limit (-1: unlimited)",
+				quotaLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(q *NetappQuota) float64 { return q.FileLimit },
+		},
+	}
+)
+
+type QuotaCollector struct {
+	ApiCollectorBase
+	Filer  NetappFilerClient
+	Quotas []*NetappQuota
+}
+
+func (q *QuotaCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range quoMetrics {
+		ch <- v.desc
+	}
+}
+
+func (q *QuotaCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range q.Quotas {
+		labels := []string{v.Vserver, v.Volume, v.Qtree, v.Type, v.Target}
+		for _, m := range quoMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+	}
+}
+
+func (q *QuotaCollector) SaveData(data []interface{}) error {
+	quotas := make([]*NetappQuota, 0)
+	for _, d := range data {
+		if quota, ok := d.(*NetappQuota); ok {
+			quotas = append(quotas, quota)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappQuota")
+		}
+	}
+	q.Quotas = quotas
+	return nil
+}
+
+func (q *QuotaCollector) Fetch(ctx context.Context) (quotas []interface{}, err error) {
+	opts := &netapp.QuotaReportOptions{
+		MaxRecords: 100,
+	}
+
+	entries, err := q.Filer.QueryQuotaReport(ctx, opts)
+
+	if err == nil {
+		logger.Printf("%s: %d quota entries fetched", q.Filer.Host, len(entries))
+		quotas = make([]interface{}, 0)
+		for _, e := range entries {
+			quotas = append(quotas, newNetappQuota(q.Filer.Name, e))
+		}
+	}
+	return
+}
+
+func newNetappQuota(filerName string, e netapp.QuotaReportEntry) *NetappQuota {
+	return &NetappQuota{
+		FilerName: filerName,
+		Vserver:   e.Vserver,
+		Volume:    e.Volume,
+		Qtree:     e.Tree,
+		Type:      e.QuotaType,
+		Target:    e.QuotaTarget,
+		// disk usage and limits are reported in KB
+		DiskUsed:  parseQuotaValue(e.DiskUsed, 1024),
+		DiskLimit: parseQuotaLimit(e.DiskLimit, 1024),
+		FilesUsed: parseQuotaValue(e.FilesUsed, 1),
+		FileLimit: parseQuotaLimit(e.FileLimit, 1),
+	}
+}
+
+func parseQuotaValue(s string, unit float64) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v * unit
+}
+
+// parseQuotaLimit returns -1 for limits that are not set, which ONTAP reports as "-".
+func parseQuotaLimit(s string, unit float64) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return -1
+	}
+	return v * unit
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetappQuota(t *testing.T) {
+	q := newNetappQuota("filer", netapp.QuotaReportEntry{
+		Vserver:     "vs1",
+		Volume:      "vol1",
+		Tree:        "qtree1",
+		QuotaType:   "tree",
+		QuotaTarget: "/vol/vol1/qtree1",
+		DiskUsed:    "2048",
+		DiskLimit:   "4096",
+		FilesUsed:   "10",
+		FileLimit:   "-",
+	})
+	assert.Equal(t, &NetappQuota{
+		FilerName: "filer",
+		Vserver:   "vs1",
+		Volume:    "vol1",
+		Qtree:     "qtree1",
+		Type:      "tree",
+		Target:    "/vol/vol1/qtree1",
+		DiskUsed:  2048 * 1024,
+		DiskLimit: 4096 * 1024,
+		FilesUsed: 10,
+		FileLimit: -1,
+	}, q)
+}
+
+func TestParseQuotaLimit(t *testing.T) {
+	assert.Equal(t, float64(1024), parseQuotaLimit("1", 1024))
+	assert.Equal(t, float64(0), parseQuotaLimit("0", 1024))
+	// limits that are not set
+	assert.Equal(t, float64(-1), parseQuotaLimit("-", 1024))
+	assert.Equal(t, float64(-1), parseQuotaLimit("", 1))
+}
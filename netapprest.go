@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pepabo/go-netapp/netapp"
+)
+
+// restBackend serves the queries from the ONTAP REST API (ONTAP 9.6 and later). The records are converted
+// to the types of go-netapp, so that the collectors do not depend on the backend.
+type restBackend struct {
+	conn    *filerConn
+	baseURL string
+	client  *http.Client
+}
+
+func newRESTBackend(conn *filerConn) *restBackend {
+	transport := conn.transport
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	return &restBackend{
+		conn:    conn,
+		baseURL: "https://" + conn.host,
+		client:  &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// httpStatusError is returned for responses of the REST API with a status other than 200.
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s: Http Error status %d, Message: %s", e.URL, e.StatusCode, e.Message)
+}
+
+type restResponse struct {
+	Records json.RawMessage `json:"records"`
+	Links   struct {
+		Next *struct {
+			Href string `json:"href"`
+		} `json:"next"`
+	} `json:"_links"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// list gets all records of the collection at path. The records of every page are passed to handle. Pages
+// are followed by the next link of the response.
+func (b *restBackend) list(ctx context.Context, path string, fields []string, maxRecords int, handle func(records json.RawMessage) error) error {
+	query := url.Values{}
+	query.Set("fields", strings.Join(fields, ","))
+	if maxRecords > 0 {
+		query.Set("max_records", strconv.Itoa(maxRecords))
+	}
+	next := path + "?" + query.Encode()
+
+	for next != "" {
+		r, err := b.get(ctx, next)
+		if err != nil {
+			return err
+		}
+		if err := handle(r.Records); err != nil {
+			return err
+		}
+		next = ""
+		if r.Links.Next != nil {
+			next = r.Links.Next.Href
+		}
+	}
+	return nil
+}
+
+func (b *restBackend) get(ctx context.Context, path string) (*restResponse, error) {
+	req, err := http.NewRequest("GET", b.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(b.conn.credentials())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	r := &restResponse{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(r)
+	if resp.StatusCode != http.StatusOK {
+		e := &httpStatusError{URL: req.URL.Path, StatusCode: resp.StatusCode}
+		if decodeErr == nil && r.Error != nil {
+			e.Message = r.Error.Message
+		}
+		return nil, e
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("%s: decode response: %v", req.URL.Path, decodeErr)
+	}
+	return r, nil
+}
+
+var restAggregateFields = []string{
+	"name",
+	"node.name",
+	"space.block_storage.size",
+	"space.block_storage.available",
+	"space.block_storage.used",
+	"space.block_storage.physical_used",
+}
+
+type restAggregate struct {
+	Name string `json:"name"`
+	Node struct {
+		Name string `json:"name"`
+	} `json:"node"`
+	Space struct {
+		BlockStorage struct {
+			Size         int `json:"size"`
+			Available    int `json:"available"`
+			Used         int `json:"used"`
+			PhysicalUsed int `json:"physical_used"`
+		} `json:"block_storage"`
+	} `json:"space"`
+}
+
+// QueryAggregates lists the aggregates, which do not include the root aggregates in the REST API. ZAPI lists
+// them unless they are filtered by the query, so both backends only return the same aggregates for queries
+// leaving out the root aggregates, like the one of AggrCollector. The options of ZAPI other than MaxRecords
+// do not apply.
+func (b *restBackend) QueryAggregates(ctx context.Context, opts *netapp.AggrOptions) (res []netapp.AggrInfo, err error) {
+	err = b.list(ctx, "/api/storage/aggregates", restAggregateFields, opts.MaxRecords, func(records json.RawMessage) error {
+		var aggrs []restAggregate
+		if err := json.Unmarshal(records, &aggrs); err != nil {
+			return err
+		}
+		for _, a := range aggrs {
+			s := a.Space.BlockStorage
+			res = append(res, netapp.AggrInfo{
+				AggregateName: a.Name,
+				AggrOwnershipAttributes: &netapp.AggrOwnershipAttributes{
+					OwnerName: a.Node.Name,
+				},
+				AggrSpaceAttributes: &netapp.AggrSpaceAttributes{
+					SizeTotal:           s.Size,
+					SizeAvailable:       s.Available,
+					SizeUsed:            s.Used,
+					PercentUsedCapacity: strconv.Itoa(percent(s.Used, s.Size)),
+					PhysicalUsed:        s.PhysicalUsed,
+					PhysicalUsedPercent: percent(s.PhysicalUsed, s.Size),
+				},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+var restVolumeFields = []string{
+	"name",
+	"uuid",
+	"comment",
+	"state",
+	"svm.name",
+	"svm.uuid",
+	"space.size",
+	"space.available",
+	"space.used",
+	"space.snapshot.used",
+	"space.snapshot.reserve_percent",
+}
+
+type restVolume struct {
+	Name    string `json:"name"`
+	UUID    string `json:"uuid"`
+	Comment string `json:"comment"`
+	State   string `json:"state"`
+	SVM     struct {
+		Name string `json:"name"`
+		UUID string `json:"uuid"`
+	} `json:"svm"`
+	Space struct {
+		Size      int `json:"size"`
+		Available int `json:"available"`
+		Used      int `json:"used"`
+		Snapshot  struct {
+			Used           int `json:"used"`
+			ReservePercent int `json:"reserve_percent"`
+		} `json:"snapshot"`
+	} `json:"space"`
+}
+
+// QueryVolumes lists the volumes with the space attributes that ZAPI reports. The storage efficiency
+// attributes are not available in the REST API. The options of ZAPI other than MaxRecords do not apply.
+func (b *restBackend) QueryVolumes(ctx context.Context, opts *netapp.VolumeOptions) (res []netapp.VolumeInfo, err error) {
+	err = b.list(ctx, "/api/storage/volumes", restVolumeFields, opts.MaxRecords, func(records json.RawMessage) error {
+		var vols []restVolume
+		if err := json.Unmarshal(records, &vols); err != nil {
+			return err
+		}
+		for _, v := range vols {
+			s := v.Space
+			// size includes the snapshot reserve, size-total of ZAPI does not
+			snapshotReserve := s.Size * s.Snapshot.ReservePercent / 100
+			sizeTotal := s.Size - snapshotReserve
+			availableForSnapshots := snapshotReserve - s.Snapshot.Used
+			if availableForSnapshots < 0 {
+				availableForSnapshots = 0
+			}
+			res = append(res, netapp.VolumeInfo{
+				VolumeIDAttributes: &netapp.VolumeIDAttributes{
+					Name:              v.Name,
+					UUID:              v.UUID,
+					Comment:           v.Comment,
+					OwningVserverName: v.SVM.Name,
+					OwningVserverUUID: v.SVM.UUID,
+				},
+				VolumeSpaceAttributes: &netapp.VolumeSpaceAttributes{
+					Size:                      s.Size,
+					SizeTotal:                 strconv.Itoa(sizeTotal),
+					SizeAvailable:             strconv.Itoa(s.Available),
+					SizeUsed:                  strconv.Itoa(s.Used),
+					SizeUsedBySnapshots:       strconv.Itoa(s.Snapshot.Used),
+					SizeAvailableForSnapshots: strconv.Itoa(availableForSnapshots),
+					SnapshotReserveSize:       strconv.Itoa(snapshotReserve),
+					PercentageSizeUsed:        strconv.Itoa(percent(s.Used, sizeTotal)),
+				},
+				VolumeStateAttributes: &netapp.VolumeStateAttributes{
+					State: v.State,
+				},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// percent returns the rounded percentage like ZAPI does.
+func percent(part, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(part) * 100 / float64(total)))
+}
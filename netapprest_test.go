@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTBackend(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "not authorized", "code": "6"}}`))
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/storage/aggregates" && r.URL.Query().Get("start") == "":
+			assert.Contains(t, r.URL.Query().Get("fields"), "space.block_storage.size")
+			w.Write([]byte(`{
+				"records": [{"name": "aggr1", "node": {"name": "node1"}, "space": {"block_storage": {"size": 1000, "available": 750, "used": 250, "physical_used": 200}}}],
+				"_links": {"next": {"href": "/api/storage/aggregates?start=2"}}
+			}`))
+		case r.URL.Path == "/api/storage/aggregates":
+			w.Write([]byte(`{"records": [{"name": "aggr2", "node": {"name": "node2"}}], "_links": {}}`))
+		case r.URL.Path == "/api/storage/volumes":
+			assert.Equal(t, "20", r.URL.Query().Get("max_records"))
+			w.Write([]byte(`{"records": [{
+				"name": "vol1", "comment": "share_id: abc", "state": "online", "svm": {"name": "vs1"},
+				"space": {"size": 1000, "available": 600, "used": 300, "snapshot": {"used": 10, "reserve_percent": 5}}
+			}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	f, err := NewNetappClient(NetappFiler{
+		Name:     "filer",
+		Host:     strings.TrimPrefix(server.URL, "https://"),
+		Username: "user",
+		Password: "secret",
+		Backend:  backendREST,
+	}, ClientOptions{})
+	assert.NoError(t, err)
+
+	aggrs, err := f.QueryAggregates(context.Background(), &netapp.AggrOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, aggrs, 2)
+	assert.Equal(t, "aggr1", aggrs[0].AggregateName)
+	assert.Equal(t, "node1", aggrs[0].AggrOwnershipAttributes.OwnerName)
+	assert.Equal(t, 1000, aggrs[0].AggrSpaceAttributes.SizeTotal)
+	assert.Equal(t, "25", aggrs[0].AggrSpaceAttributes.PercentUsedCapacity)
+	assert.Equal(t, 20, aggrs[0].AggrSpaceAttributes.PhysicalUsedPercent)
+	assert.Equal(t, "aggr2", aggrs[1].AggregateName)
+
+	vols, err := f.QueryVolumes(context.Background(), &netapp.VolumeOptions{MaxRecords: 20})
+	assert.NoError(t, err)
+	assert.Len(t, vols, 1)
+	assert.Equal(t, "vs1", vols[0].VolumeIDAttributes.OwningVserverName)
+	assert.Equal(t, "online", vols[0].VolumeStateAttributes.State)
+	assert.Equal(t, "950", vols[0].VolumeSpaceAttributes.SizeTotal)
+	assert.Equal(t, "50", vols[0].VolumeSpaceAttributes.SnapshotReserveSize)
+	assert.Equal(t, "40", vols[0].VolumeSpaceAttributes.SizeAvailableForSnapshots)
+	assert.Equal(t, "32", vols[0].VolumeSpaceAttributes.PercentageSizeUsed)
+
+	// unknown savings are not exported as 0
+	c := &VolumeCollector{Filer: f}
+	data, err := c.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, c.SaveData(data))
+	assert.False(t, data[0].(*NetappVolume).HasSavings)
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(""), "netapp_volume_saved_total_percentage"))
+
+	f.conn.password = "wrong"
+	_, err = f.QueryVolumes(context.Background(), &netapp.VolumeOptions{})
+	assert.True(t, isAuthError(err))
+	assert.Contains(t, err.Error(), "not authorized")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetappSnapMirror(t *testing.T) {
+	for state, expected := range map[string]int{
+		"snapmirrored":  1,
+		"uninitialized": 2,
+		"broken-off":    3,
+		"source":        0,
+	} {
+		var n snapmirrorInfo
+		n.MirrorState = state
+		assert.Equal(t, expected, newNetappSnapMirror("filer", n).State, state)
+	}
+}
+
+func TestSnapMirrorCollectorCollect(t *testing.T) {
+	// ONTAP omits the lag and the last transfer of relationships that have never transferred
+	var list struct {
+		Infos []snapmirrorInfo `xml:"snapmirror-info"`
+	}
+	assert.NoError(t, xml.Unmarshal([]byte(`<attributes-list>
+<snapmirror-info>
+  <source-location>vs1:vol1</source-location>
+  <destination-location>vs2:vol1</destination-location>
+  <mirror-state>snapmirrored</mirror-state>
+  <is-healthy>true</is-healthy>
+  <lag-time>0</lag-time>
+  <last-transfer-size>4096</last-transfer-size>
+  <last-transfer-duration>3</last-transfer-duration>
+</snapmirror-info>
+<snapmirror-info>
+  <source-location>vs1:vol2</source-location>
+  <destination-location>vs2:vol2</destination-location>
+  <mirror-state>uninitialized</mirror-state>
+  <is-healthy>false</is-healthy>
+</snapmirror-info>
+</attributes-list>`), &list))
+
+	c := &SnapMirrorCollector{}
+	for _, n := range list.Infos {
+		c.Snapmirrors = append(c.Snapmirrors, newNetappSnapMirror("filer", n))
+	}
+
+	expected := `
+# HELP netapp_snapmirror_lag_time_seconds Netapp Snapmirror Metrics: time since the newest snapshot was transferred
+# TYPE netapp_snapmirror_lag_time_seconds gauge
+netapp_snapmirror_lag_time_seconds{destination="vs2:vol1",source="vs1:vol1"} 0
+# HELP netapp_snapmirror_last_transfer_size_bytes Netapp Snapmirror Metrics: size of the last transfer
+# TYPE netapp_snapmirror_last_transfer_size_bytes gauge
+netapp_snapmirror_last_transfer_size_bytes{destination="vs2:vol1",source="vs1:vol1"} 4096
+# HELP netapp_snapmirror_state Netapp Snapmirror Metrics: mirror state (0: other; 1: snapmirrored; 2: uninitialized; 3: broken-off)
+# TYPE netapp_snapmirror_state gauge
+netapp_snapmirror_state{destination="vs2:vol1",source="vs1:vol1"} 1
+netapp_snapmirror_state{destination="vs2:vol2",source="vs1:vol2"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"netapp_snapmirror_lag_time_seconds", "netapp_snapmirror_last_transfer_size_bytes", "netapp_snapmirror_state"))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NetappSnapshot summarizes the snapshots of a single volume.
+type NetappSnapshot struct {
+	FilerName  string
+	Vserver    string
+	Volume     string
+	ProjectID  string
+	ShareID    string
+	Count      int
+	OldestTime time.Time
+	NewestTime time.Time
+	TotalBytes float64
+}
+
+type snapshotMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(s *NetappSnapshot) float64
+}
+
+var (
+	snapMetrics = snapshotMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_snapshot_count",
+				"Netapp Snapshot Metrics: number of snapshots",
+				volumeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(s *NetappSnapshot) float64 { return float64(s.Count) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_snapshot_oldest_age_seconds",
+				"Netapp Snapshot Metrics: age of the oldest snapshot",
+				volumeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(s *NetappSnapshot) float64 { return time.Since(s.OldestTime).Seconds() },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_snapshot_newest_age_seconds",
+				"Netapp Snapshot Metrics: age of the newest snapshot",
+				volumeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(s *NetappSnapshot) float64 { return time.Since(s.NewestTime).Seconds() },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_snapshot_total_bytes",
+				"Netapp Snapshot Metrics: total size of all snapshots",
+				volumeLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(s *NetappSnapshot) float64 { return s.TotalBytes },
+		},
+	}
+)
+
+type SnapshotCollector struct {
+	ApiCollectorBase
+	Filer     NetappFilerClient
+	Snapshots []*NetappSnapshot
+}
+
+func (s *SnapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range snapMetrics {
+		ch <- v.desc
+	}
+}
+
+func (s *SnapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range s.Snapshots {
+		labels := []string{v.Vserver, v.Volume, v.ProjectID, v.ShareID}
+		for _, m := range snapMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+		}
+	}
+}
+
+func (s *SnapshotCollector) SaveData(data []interface{}) error {
+	snaps := make([]*NetappSnapshot, 0)
+	for _, d := range data {
+		if snap, ok := d.(*NetappSnapshot); ok {
+			snaps = append(snaps, snap)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappSnapshot")
+		}
+	}
+	s.Snapshots = snaps
+	return nil
+}
+
+func (s *SnapshotCollector) Fetch(ctx context.Context) (snapshots []interface{}, err error) {
+	snapshotOptions := netapp.SnapshotOptions{
+		MaxRecords: 100,
+		DesiredAttributes: &netapp.SnapshotQuery{
+			SnapshotInfo: &netapp.SnapshotInfo{},
+		},
+	}
+
+	snaps, err := s.Filer.QuerySnapshots(ctx, &snapshotOptions)
+	if err != nil {
+		return
+	}
+	logger.Printf("%s: %d snapshots fetched", s.Filer.Host, len(snaps))
+
+	// Snapshots carry no volume comment, so the project and share ids are looked up from the volumes.
+	volumeOptions := netapp.VolumeOptions{
+		MaxRecords: volumeListMaxRecords,
+		DesiredAttributes: &netapp.VolumeQuery{
+			VolumeInfo: &netapp.VolumeInfo{
+				VolumeIDAttributes: &netapp.VolumeIDAttributes{
+					Name:              "x",
+					OwningVserverName: "x",
+					Comment:           "x",
+				},
+			},
+		},
+	}
+
+	vols, err := s.Filer.QueryVolumes(ctx, &volumeOptions)
+	if err != nil {
+		return
+	}
+
+	comments := make(map[string]string)
+	for _, vol := range vols {
+		if vol.VolumeIDAttributes != nil {
+			comments[vol.VolumeIDAttributes.OwningVserverName+"/"+vol.VolumeIDAttributes.Name] = vol.VolumeIDAttributes.Comment
+		}
+	}
+
+	snapshots = make([]interface{}, 0)
+	for _, ns := range summarizeSnapshots(snaps) {
+		ns.FilerName = s.Filer.Name
+		if c := comments[ns.Vserver+"/"+ns.Volume]; c != "" {
+			shareID, _, projectID, err := parseVolumeComment(c)
+			if err != nil {
+				logger.Debug(err)
+			} else {
+				ns.ShareID = shareID
+				ns.ProjectID = projectID
+			}
+		}
+		snapshots = append(snapshots, ns)
+	}
+	return
+}
+
+// summarizeSnapshots groups snapshots by vserver and volume. The order of the result follows the first
+// appearance of each volume in snaps.
+func summarizeSnapshots(snaps []netapp.SnapshotInfo) (res []*NetappSnapshot) {
+	index := make(map[string]*NetappSnapshot)
+	for _, snap := range snaps {
+		key := snap.Vserver + "/" + snap.Volume
+		created := time.Unix(int64(snap.AccessTime), 0)
+		ns, ok := index[key]
+		if !ok {
+			ns = &NetappSnapshot{
+				Vserver:    snap.Vserver,
+				Volume:     snap.Volume,
+				OldestTime: created,
+				NewestTime: created,
+			}
+			index[key] = ns
+			res = append(res, ns)
+		}
+		ns.Count++
+		// total is reported in 1024-byte blocks
+		ns.TotalBytes += float64(snap.Total) * 1024
+		if created.Before(ns.OldestTime) {
+			ns.OldestTime = created
+		}
+		if created.After(ns.NewestTime) {
+			ns.NewestTime = created
+		}
+	}
+	return
+}
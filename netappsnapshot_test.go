@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeSnapshots(t *testing.T) {
+	snaps := []netapp.SnapshotInfo{
+		{Vserver: "vs1", Volume: "vol1", AccessTime: 1500, Total: 2},
+		{Vserver: "vs1", Volume: "vol2", AccessTime: 1000, Total: 1},
+		{Vserver: "vs1", Volume: "vol1", AccessTime: 1000, Total: 3},
+		{Vserver: "vs1", Volume: "vol1", AccessTime: 2000, Total: 0},
+	}
+
+	res := summarizeSnapshots(snaps)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "vol1", res[0].Volume)
+	assert.Equal(t, 3, res[0].Count)
+	assert.Equal(t, int64(1000), res[0].OldestTime.Unix())
+	assert.Equal(t, int64(2000), res[0].NewestTime.Unix())
+	assert.Equal(t, float64(5*1024), res[0].TotalBytes)
+	assert.Equal(t, "vol2", res[1].Volume)
+	assert.Equal(t, 1, res[1].Count)
+}
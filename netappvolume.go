@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -9,6 +10,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// volumeListMaxRecords is the page size of the volume listings of the other collectors. They only request the
+// names and comments of the volumes, so their pages can be much larger than the ones of VolumeCollector.
+const volumeListMaxRecords = 1000
+
 type NetappVolume struct {
 	ProjectID                         string
 	ShareID                           string
@@ -29,6 +34,8 @@ type NetappVolume struct {
 	PercentageCompressionSpaceSaved   float64
 	PercentageDeduplicationSpaceSaved float64
 	PercentageTotalSpaceSaved         float64
+	// HasSavings is set when the filer reported the storage efficiency, which the rest backend does not.
+	HasSavings bool
 }
 
 type volumeMetrics []struct {
@@ -111,7 +118,13 @@ var (
 				nil),
 			valType: prometheus.GaugeValue,
 			evalFn:  func(v *NetappVolume) float64 { return v.PercentageSizeUsed },
-		}, {
+		},
+	}
+
+	// volSavedMetrics are only exported for volumes with storage efficiency attributes, so that unknown
+	// savings are not exported as 0.
+	volSavedMetrics = volumeMetrics{
+		{
 			desc: prometheus.NewDesc(
 				"netapp_volume_saved_total_percentage",
 				"Netapp Volume Metrics: percentage of space compression and deduplication saved",
@@ -149,6 +162,9 @@ func (v *VolumeCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, v := range volMetrics {
 		ch <- v.desc
 	}
+	for _, v := range volSavedMetrics {
+		ch <- v.desc
+	}
 }
 
 func (v *VolumeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -157,6 +173,11 @@ func (v *VolumeCollector) Collect(ch chan<- prometheus.Metric) {
 		for _, m := range volMetrics {
 			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
 		}
+		if v.HasSavings {
+			for _, m := range volSavedMetrics {
+				ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(v), labels...)
+			}
+		}
 	}
 }
 
@@ -173,7 +194,7 @@ func (v *VolumeCollector) SaveData(data []interface{}) error {
 	return nil
 }
 
-func (v *VolumeCollector) Fetch() (volumes []interface{}, err error) {
+func (v *VolumeCollector) Fetch(ctx context.Context) (volumes []interface{}, err error) {
 	volumeOptions := netapp.VolumeOptions{
 		MaxRecords: 20,
 		DesiredAttributes: &netapp.VolumeQuery{
@@ -206,7 +227,7 @@ func (v *VolumeCollector) Fetch() (volumes []interface{}, err error) {
 		},
 	}
 
-	vols, err := v.Filer.QueryVolumes(&volumeOptions)
+	vols, err := v.Filer.QueryVolumes(ctx, &volumeOptions)
 
 	if err == nil {
 		logger.Printf("%s: %d volumes fetched", v.Filer.Host, len(vols))
@@ -251,9 +272,13 @@ func (v *VolumeCollector) Fetch() (volumes []interface{}, err error) {
 				nv.PercentageCompressionSpaceSaved = percentageCompressionSpaceSaved
 				nv.PercentageDeduplicationSpaceSaved = percentageDeduplicationSpaceSaved
 				nv.PercentageTotalSpaceSaved = percentageTotalSpaceSaved
+				nv.HasSavings = true
 			} else {
-				logger.Warnf("%s has no VolumeSisAttributes", vol.VolumeIDAttributes.Name)
-				logger.Debugf("%+v", vol.VolumeIDAttributes)
+				// the rest backend does not report the storage efficiency
+				if v.Filer.Backend != backendREST {
+					logger.Warnf("%s has no VolumeSisAttributes", vol.VolumeIDAttributes.Name)
+					logger.Debugf("%+v", vol.VolumeIDAttributes)
+				}
 			}
 			if vol.VolumeIDAttributes.Comment != "" {
 				shareID, shareName, projectID, err := parseVolumeComment(vol.VolumeIDAttributes.Comment)
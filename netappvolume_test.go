@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	_ "github.com/motemen/go-loghttp/global"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,3 +24,25 @@ func TestParseVolumeComment(t *testing.T) {
 	assert.Equal(t, "c_blackbox_1553028005", name)
 	assert.Equal(t, "d940aae3f8084f15a9b67de5b3b39720", project)
 }
+
+func TestVolumeCollectorFetch(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+
+	c := &VolumeCollector{Filer: newFakeFiler(t, s)}
+	data, err := c.Fetch(context.Background())
+	assert.NoError(t, err)
+	// 50 volumes are fetched in pages of 20
+	assert.Len(t, data, 50)
+	assert.Equal(t, 3, s.Requests("volume-get-iter"))
+
+	v := data[49].(*NetappVolume)
+	assert.Equal(t, "vol049", v.Volume)
+	assert.Equal(t, "vs1", v.Vserver)
+	assert.Equal(t, "share-049", v.ShareID)
+	assert.Equal(t, "project1", v.ProjectID)
+	assert.Equal(t, 1, v.State)
+	assert.Equal(t, float64(49<<30), v.SizeUsed)
+	assert.Equal(t, float64(10), v.PercentageTotalSpaceSaved)
+	assert.True(t, v.HasSavings)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NetappVolumePerf holds the raw counters of the volume perf object, which are cumulative since the start of
+// the node. Rates are computed from two consecutive samples of the same instance.
+type NetappVolumePerf struct {
+	FilerName  string
+	UUID       string
+	Vserver    string
+	Volume     string
+	SampleTime time.Time
+	ReadOps    float64
+	WriteOps   float64
+	TotalOps   float64
+	ReadBytes  float64
+	WriteBytes float64
+	AvgLatency float64
+
+	HasRates       bool
+	ReadOpsRate    float64
+	WriteOpsRate   float64
+	ReadBytesRate  float64
+	WriteBytesRate float64
+	Latency        float64
+}
+
+type volumePerfMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(p *NetappVolumePerf) float64
+}
+
+var (
+	volumePerfLabels = []string{
+		"vserver",
+		"volume",
+	}
+
+	volumePerfCounters = []string{
+		"vserver_name",
+		"read_ops",
+		"write_ops",
+		"total_ops",
+		"read_data",
+		"write_data",
+		"avg_latency",
+	}
+
+	volPerfMetrics = volumePerfMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_read_ops_total",
+				"Netapp Volume Performance Metrics: read operations",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.CounterValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.ReadOps },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_write_ops_total",
+				"Netapp Volume Performance Metrics: write operations",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.CounterValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.WriteOps },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_read_bytes_total",
+				"Netapp Volume Performance Metrics: bytes read",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.CounterValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.ReadBytes },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_write_bytes_total",
+				"Netapp Volume Performance Metrics: bytes written",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.CounterValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.WriteBytes },
+		},
+	}
+
+	// rate metrics are only exported after the second sample of a volume
+	volPerfRateMetrics = volumePerfMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_read_ops_per_second",
+				"Netapp Volume Performance Metrics: read operations per second between the last two samples",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.ReadOpsRate },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_write_ops_per_second",
+				"Netapp Volume Performance Metrics: write operations per second between the last two samples",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.WriteOpsRate },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_read_bytes_per_second",
+				"Netapp Volume Performance Metrics: bytes read per second between the last two samples",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.ReadBytesRate },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_write_bytes_per_second",
+				"Netapp Volume Performance Metrics: bytes written per second between the last two samples",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.WriteBytesRate },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_volume_perf_avg_latency_seconds",
+				"Netapp Volume Performance Metrics: average operation latency between the last two samples",
+				volumePerfLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(p *NetappVolumePerf) float64 { return p.Latency },
+		},
+	}
+)
+
+type VolumePerfCollector struct {
+	ApiCollectorBase
+	Filer   NetappFilerClient
+	Volumes []*NetappVolumePerf
+}
+
+func (v *VolumePerfCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range volPerfMetrics {
+		ch <- m.desc
+	}
+	for _, m := range volPerfRateMetrics {
+		ch <- m.desc
+	}
+}
+
+func (v *VolumePerfCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range v.Volumes {
+		labels := []string{p.Vserver, p.Volume}
+		for _, m := range volPerfMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(p), labels...)
+		}
+		if !p.HasRates {
+			continue
+		}
+		for _, m := range volPerfRateMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(p), labels...)
+		}
+	}
+}
+
+// SaveData computes the rates of the new samples against the samples saved by the previous call.
+func (v *VolumePerfCollector) SaveData(data []interface{}) error {
+	prev := make(map[string]*NetappVolumePerf)
+	for _, p := range v.Volumes {
+		prev[p.UUID] = p
+	}
+
+	vols := make([]*NetappVolumePerf, 0)
+	for _, d := range data {
+		if p, ok := d.(*NetappVolumePerf); ok {
+			if last, ok := prev[p.UUID]; ok {
+				computeVolumePerfRates(last, p)
+			}
+			vols = append(vols, p)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappVolumePerf")
+		}
+	}
+	v.Volumes = vols
+	return nil
+}
+
+func (v *VolumePerfCollector) Fetch(ctx context.Context) (volumes []interface{}, err error) {
+	opts := &perfObjectInstanceListInfoIterOptions{
+		ObjectName: "volume",
+		MaxRecords: 100,
+	}
+
+	instances, err := v.Filer.QueryPerfInstances(ctx, opts, volumePerfCounters)
+
+	if err == nil {
+		logger.Printf("%s: %d volume perf instances fetched", v.Filer.Host, len(instances))
+		now := time.Now()
+		volumes = make([]interface{}, 0)
+		for _, i := range instances {
+			p := &NetappVolumePerf{
+				FilerName:  v.Filer.Name,
+				UUID:       i.Uuid,
+				Volume:     i.Name,
+				SampleTime: now,
+			}
+			for _, c := range i.Counters {
+				if c.Name == "vserver_name" {
+					p.Vserver = c.Value
+					continue
+				}
+				value, _ := strconv.ParseFloat(c.Value, 64)
+				switch c.Name {
+				case "read_ops":
+					p.ReadOps = value
+				case "write_ops":
+					p.WriteOps = value
+				case "total_ops":
+					p.TotalOps = value
+				case "read_data":
+					p.ReadBytes = value
+				case "write_data":
+					p.WriteBytes = value
+				case "avg_latency":
+					p.AvgLatency = value
+				}
+			}
+			volumes = append(volumes, p)
+		}
+	}
+	return
+}
+
+// computeVolumePerfRates sets the rates of cur from the difference to prev. Rates are left unset if the
+// counters have been reset in between, e.g. after a node reboot or volume move.
+func computeVolumePerfRates(prev, cur *NetappVolumePerf) {
+	dt := cur.SampleTime.Sub(prev.SampleTime).Seconds()
+	if dt <= 0 || cur.TotalOps < prev.TotalOps || cur.ReadBytes < prev.ReadBytes || cur.WriteBytes < prev.WriteBytes {
+		return
+	}
+
+	cur.HasRates = true
+	cur.ReadOpsRate = (cur.ReadOps - prev.ReadOps) / dt
+	cur.WriteOpsRate = (cur.WriteOps - prev.WriteOps) / dt
+	cur.ReadBytesRate = (cur.ReadBytes - prev.ReadBytes) / dt
+	cur.WriteBytesRate = (cur.WriteBytes - prev.WriteBytes) / dt
+	// avg_latency is accumulated in microseconds over total_ops
+	if ops := cur.TotalOps - prev.TotalOps; ops > 0 {
+		cur.Latency = (cur.AvgLatency - prev.AvgLatency) / ops / 1e6
+	}
+}
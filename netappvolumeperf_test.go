@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeVolumePerfRates(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	prev := &NetappVolumePerf{SampleTime: t0, ReadOps: 100, WriteOps: 50, TotalOps: 200, ReadBytes: 4096, WriteBytes: 0, AvgLatency: 1000}
+	cur := &NetappVolumePerf{SampleTime: t0.Add(10 * time.Second), ReadOps: 200, WriteOps: 150, TotalOps: 400, ReadBytes: 8192, WriteBytes: 1024, AvgLatency: 201000}
+
+	computeVolumePerfRates(prev, cur)
+	assert.True(t, cur.HasRates)
+	assert.Equal(t, 10.0, cur.ReadOpsRate)
+	assert.Equal(t, 10.0, cur.WriteOpsRate)
+	assert.Equal(t, 409.6, cur.ReadBytesRate)
+	assert.Equal(t, 102.4, cur.WriteBytesRate)
+	assert.Equal(t, 0.001, cur.Latency)
+
+	// counters reset, e.g. after a reboot
+	reset := &NetappVolumePerf{SampleTime: t0.Add(20 * time.Second), TotalOps: 10}
+	computeVolumePerfRates(cur, reset)
+	assert.False(t, reset.HasRates)
+}
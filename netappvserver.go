@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type NetappVserver struct {
+	FilerName        string
+	Name             string
+	State            int
+	RootVolume       string
+	AllowedProtocols string
+	VolumeCount      int
+}
+
+type vserverMetrics []struct {
+	desc    *prometheus.Desc
+	valType prometheus.ValueType
+	evalFn  func(vs *NetappVserver) float64
+}
+
+var (
+	vserverLabels = []string{
+		"vserver",
+	}
+
+	vsMetrics = vserverMetrics{
+		{
+			desc: prometheus.NewDesc(
+				"netapp_vserver_state",
+				"Netapp Vserver Metrics: state (0: other; 1: running; 2: stopped; 3: starting; 4: stopping; 5: initializing; 6: deleting)",
+				vserverLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(vs *NetappVserver) float64 { return float64(vs.State) },
+		}, {
+			desc: prometheus.NewDesc(
+				"netapp_vserver_volumes",
+				"Netapp Vserver Metrics: number of volumes",
+				vserverLabels,
+				nil),
+			valType: prometheus.GaugeValue,
+			evalFn:  func(vs *NetappVserver) float64 { return float64(vs.VolumeCount) },
+		},
+	}
+
+	vserverInfoDesc = prometheus.NewDesc(
+		"netapp_vserver_info",
+		"Netapp Vserver Metrics: root volume and allowed protocols",
+		[]string{"vserver", "root_volume", "allowed_protocols"},
+		nil)
+)
+
+type VserverCollector struct {
+	ApiCollectorBase
+	Filer    NetappFilerClient
+	Vservers []*NetappVserver
+}
+
+func (v *VserverCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range vsMetrics {
+		ch <- m.desc
+	}
+	ch <- vserverInfoDesc
+}
+
+func (v *VserverCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, vs := range v.Vservers {
+		labels := []string{vs.Name}
+		for _, m := range vsMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valType, m.evalFn(vs), labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(vserverInfoDesc, prometheus.GaugeValue, 1, vs.Name, vs.RootVolume, vs.AllowedProtocols)
+	}
+}
+
+func (v *VserverCollector) SaveData(data []interface{}) error {
+	vservers := make([]*NetappVserver, 0)
+	for _, d := range data {
+		if vs, ok := d.(*NetappVserver); ok {
+			vservers = append(vservers, vs)
+		} else {
+			return fmt.Errorf("type of parameter should be %s", "[]*NetappVserver")
+		}
+	}
+	v.Vservers = vservers
+	return nil
+}
+
+func (v *VserverCollector) Fetch(ctx context.Context) (vservers []interface{}, err error) {
+	opts := &vserverGetIterOptions{
+		MaxRecords: 100,
+		Query: &netapp.VServerQuery{
+			VServerInfo: &netapp.VServerInfo{
+				VserverType: "data",
+			},
+		},
+	}
+
+	res, err := v.Filer.QueryVservers(ctx, opts)
+	if err != nil {
+		return
+	}
+	logger.Printf("%s: %d vservers fetched", v.Filer.Host, len(res))
+
+	volumeOptions := netapp.VolumeOptions{
+		MaxRecords: volumeListMaxRecords,
+		DesiredAttributes: &netapp.VolumeQuery{
+			VolumeInfo: &netapp.VolumeInfo{
+				VolumeIDAttributes: &netapp.VolumeIDAttributes{
+					Name:              "x",
+					OwningVserverName: "x",
+				},
+			},
+		},
+	}
+
+	vols, err := v.Filer.QueryVolumes(ctx, &volumeOptions)
+	if err != nil {
+		return
+	}
+
+	volumeCount := make(map[string]int)
+	for _, vol := range vols {
+		if vol.VolumeIDAttributes != nil {
+			volumeCount[vol.VolumeIDAttributes.OwningVserverName]++
+		}
+	}
+
+	vservers = make([]interface{}, 0)
+	for _, n := range res {
+		vservers = append(vservers, newNetappVserver(v.Filer.Name, n, volumeCount[n.VserverName]))
+	}
+	return
+}
+
+func newNetappVserver(filerName string, n netapp.VServerInfo, volumeCount int) *NetappVserver {
+	vs := &NetappVserver{
+		FilerName:   filerName,
+		Name:        n.VserverName,
+		RootVolume:  n.RootVolume,
+		VolumeCount: volumeCount,
+	}
+	if n.AllowedProtocols != nil {
+		protocols := append([]string{}, *n.AllowedProtocols...)
+		sort.Strings(protocols)
+		vs.AllowedProtocols = strings.Join(protocols, ",")
+	}
+	switch n.State {
+	case "running":
+		vs.State = 1
+	case "stopped":
+		vs.State = 2
+	case "starting":
+		vs.State = 3
+	case "stopping":
+		vs.State = 4
+	case "initializing":
+		vs.State = 5
+	case "deleting":
+		vs.State = 6
+	}
+	return vs
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/pepabo/go-netapp/netapp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetappVserver(t *testing.T) {
+	var n netapp.VServerInfo
+	assert.NoError(t, xml.Unmarshal([]byte(`<vserver-info>
+  <vserver-name>vs1</vserver-name>
+  <state>running</state>
+  <root-volume>vs1_root</root-volume>
+  <allowed-protocols><protocol>nfs</protocol><protocol>cifs</protocol></allowed-protocols>
+</vserver-info>`), &n))
+	// the protocols are sorted, so that the info series does not change with their order
+	assert.Equal(t, &NetappVserver{
+		FilerName:        "filer",
+		Name:             "vs1",
+		State:            1,
+		RootVolume:       "vs1_root",
+		AllowedProtocols: "cifs,nfs",
+		VolumeCount:      3,
+	}, newNetappVserver("filer", n, 3))
+
+	for state, expected := range map[string]int{
+		"running":      1,
+		"stopped":      2,
+		"starting":     3,
+		"stopping":     4,
+		"initializing": 5,
+		"deleting":     6,
+		"unknown":      0,
+	} {
+		vs := newNetappVserver("filer", netapp.VServerInfo{State: state}, 0)
+		assert.Equal(t, expected, vs.State, state)
+	}
+
+	// vservers without allowed protocols
+	assert.Equal(t, "", newNetappVserver("filer", netapp.VServerInfo{}, 0).AllowedProtocols)
+}
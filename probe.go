@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeTimeoutOffset is subtracted from the scrape timeout of Prometheus, so that the probe responds before
+// Prometheus gives up.
+const probeTimeoutOffset = 500 * time.Millisecond
+
+// probeHandler serves /probe?target=<filer-name-or-host>&module=<collectors>. It fetches the collectors of
+// the module from the target and exports the data with a fresh registry, so that filers can be discovered
+// and sharded by Prometheus. The target is the name or host of a registered filer.
+func probeHandler(r *FilerRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		target := req.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		// only registered filers are probed, so that the exporter does not send credentials to arbitrary hosts
+		registered, ok := r.Lookup(target)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusNotFound)
+			return
+		}
+		filer := registered.filer
+
+		if module := req.URL.Query().Get("module"); module != "" {
+			collectors, err := parseProbeModule(module)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, c := range collectors {
+				if filer.Backend == backendREST && !isRESTCollector(c.Name) {
+					http.Error(w, fmt.Sprintf("collector %q is not available with the rest backend", c.Name), http.StatusBadRequest)
+					return
+				}
+			}
+			filer.Collectors = collectors
+		}
+
+		ctx, cancel, err := probeContext(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer cancel()
+
+		start := time.Now()
+		cc := NewNetappCollector(filer)
+		// probes count towards the max_concurrent_fetches of the registered filer
+		cc.limiter = registered.limiter
+		success := cc.Probe(ctx)
+		duration := time.Since(start).Seconds()
+
+		labels := prometheus.Labels{
+			"filer":             filer.Name,
+			"availability_zone": filer.AvailabilityZone,
+		}
+		probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "netapp",
+			Subsystem: "probe",
+			Name:      "success",
+			Help:      "All collectors of the probe fetched their data successfully (1: success; 0: failure).",
+		})
+		probeSuccess.Set(boolToFloat(success))
+		probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "netapp",
+			Subsystem: "probe",
+			Name:      "duration_seconds",
+			Help:      "Duration of the probe.",
+		})
+		probeDuration.Set(duration)
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(probeSuccess, probeDuration)
+		prometheus.WrapRegistererWith(labels, reg).MustRegister(cc)
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+	}
+}
+
+// probeContext returns the context of the request with the deadline of the scrape timeout in the
+// X-Prometheus-Scrape-Timeout-Seconds header less probeTimeoutOffset, so that the fetches of a probe do not
+// hold the fetch slots of the filer after Prometheus has given up.
+func probeContext(req *http.Request) (context.Context, context.CancelFunc, error) {
+	header := req.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		ctx, cancel := context.WithCancel(req.Context())
+		return ctx, cancel, nil
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return nil, nil, fmt.Errorf("invalid X-Prometheus-Scrape-Timeout-Seconds %q", header)
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > probeTimeoutOffset {
+		timeout -= probeTimeoutOffset
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// parseProbeModule parses the module parameter of a probe, which is a comma separated list of collectors.
+func parseProbeModule(module string) (collectors []CollectorConfig, err error) {
+	for _, name := range strings.Split(module, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := collectorFactories[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		collectors = append(collectors, CollectorConfig{Name: name})
+	}
+	return
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbeModule(t *testing.T) {
+	collectors, err := parseProbeModule("volume, aggregate")
+	assert.NoError(t, err)
+	assert.Equal(t, []CollectorConfig{{Name: "volume"}, {Name: "aggregate"}}, collectors)
+
+	_, err = parseProbeModule("volume,foo")
+	assert.Error(t, err)
+}
+
+func TestProbeHandler(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+
+	r := NewFilerRegistry(prometheus.NewRegistry(), ClientOptions{})
+	defer r.Shutdown(context.Background())
+	assert.NoError(t, r.Reconcile([]NetappFiler{fakeFilerConfig(s, "aggregate")}))
+
+	probe := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		probeHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/probe?"+query, nil))
+		return w
+	}
+
+	w := probe("target=" + s.Host() + "&module=volume")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "netapp_probe_success 1")
+	assert.Contains(t, w.Body.String(), `netapp_volume_total_bytes{availability_zone="",filer="fake"`)
+
+	// hosts that are not configured are not probed
+	w = probe("target=unknown.example.com")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = probe("target=fake&module=foo")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestProbeContext(t *testing.T) {
+	req := httptest.NewRequest("GET", "/probe?target=fake", nil)
+	ctx, cancel, err := probeContext(req)
+	assert.NoError(t, err)
+	cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	// the probe ends before the scrape timeout of Prometheus
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+	ctx, cancel, err = probeContext(req)
+	assert.NoError(t, err)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second-probeTimeoutOffset), deadline, time.Second)
+
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "soon")
+	_, _, err = probeContext(req)
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// redactedHeaders are the headers that are not written to recordings.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// recordOptions returns the client options recording the requests of every filer to a directory named after
+// the filer in dir.
+func recordOptions(dir string) ClientOptions {
+	return ClientOptions{
+		WrapTransport: func(filer string, t http.RoundTripper) http.RoundTripper {
+			return &recordTransport{dir: filepath.Join(dir, filer), transport: t}
+		},
+	}
+}
+
+// replayOptions returns the client options serving the requests of every filer from the recordings in the
+// directory named after the filer in dir. The filers are not contacted, so the configured credentials are
+// not read; the secret store might not be reachable either.
+func replayOptions(dir string) ClientOptions {
+	return ClientOptions{
+		WrapTransport: func(filer string, _ http.RoundTripper) http.RoundTripper {
+			return &replayTransport{dir: filepath.Join(dir, filer)}
+		},
+		Credentials: replayCredentials{},
+	}
+}
+
+// replayCredentials are sent with replayed requests, which are not checked against any credentials.
+type replayCredentials struct{}
+
+func (replayCredentials) Credentials() (string, string, error) {
+	return "replay", "replay", nil
+}
+
+// recording is a request to a filer and its response. Recordings are stored as JSON files in a directory per
+// filer, named after the API and the hash of the request.
+type recording struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	Header         http.Header `json:"header"`
+	Request        string      `json:"request"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header"`
+	Response       string      `json:"response"`
+}
+
+// recordTransport writes the requests made through it and their responses to dir.
+type recordTransport struct {
+	dir       string
+	transport http.RoundTripper
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r := recording{
+		Method:         req.Method,
+		URL:            redactURL(req),
+		Header:         redactHeader(req.Header),
+		Request:        string(reqBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: redactHeader(resp.Header),
+		Response:       string(respBody),
+	}
+	// a failed recording does not fail the request
+	if err := writeRecording(t.dir, recordingName(req, reqBody), r); err != nil {
+		logger.Errorf("record %s: %v", req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// replayTransport serves the requests made through it from the recordings in dir.
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	name := recordingName(req, reqBody)
+	b, err := ioutil.ReadFile(filepath.Join(t.dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recording of %s %s in %s", req.Method, req.URL.Path, t.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r recording
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("decode recording %s: %v", name, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.ResponseHeader,
+		Body:          ioutil.NopCloser(strings.NewReader(r.Response)),
+		ContentLength: int64(len(r.Response)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the body of req and replaces it, so that it can be sent afterwards.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// recordingName identifies a request by the method, the path and query of its URL and its body. Neither the
+// host nor the credentials are part of it, so that recordings can be replayed for another filer.
+func recordingName(req *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL.RequestURI())
+	h.Write(body)
+	return fmt.Sprintf("%s-%s.json", apiName(req, body), hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// apiName returns the name of the ZAPI call in body, e.g. volume-get-iter, or the last element of the path
+// for the REST API.
+func apiName(req *http.Request, body []byte) string {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			break
+		}
+		if e, ok := t.(xml.StartElement); ok {
+			if depth == 1 {
+				return e.Name.Local
+			}
+			depth++
+		}
+	}
+	return path.Base(req.URL.Path)
+}
+
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	return u.String()
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = cloneHeader(h)
+	for _, name := range redactedHeaders {
+		if _, ok := h[name]; ok {
+			h.Set(name, "REDACTED")
+		}
+	}
+	return h
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// writeRecording writes r to a temporary file first, so that a recording being replayed is never
+// incomplete.
+func writeRecording(dir, name string, r recording) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".recording")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, name))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := fakezapi.NewServer()
+	f, err := NewNetappClient(fakeFilerConfig(s), recordOptions(dir))
+	assert.NoError(t, err)
+	c := &VolumeCollector{Filer: f}
+	recorded, err := c.Fetch(context.Background())
+	assert.NoError(t, err)
+	s.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, "fake", "volume-get-iter-*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "REDACTED")
+		// base64 of user:secret
+		assert.False(t, strings.Contains(string(b), "dXNlcjpzZWNyZXQ="), "%s contains credentials", f)
+	}
+
+	// the server is closed, so the responses come from the recordings; no credentials are needed for them
+	config := fakeFilerConfig(s)
+	config.Username, config.Password = "", ""
+	f, err = NewNetappClient(config, replayOptions(dir))
+	assert.NoError(t, err)
+	c = &VolumeCollector{Filer: f}
+	replayed, err := c.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	a := &AggrCollector{Filer: f}
+	_, err = a.Fetch(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FilerRegistry keeps track of the NetappCollectors registered per filer, so that the registered filers can
+// be reconciled with the config file.
+type FilerRegistry struct {
+	sync.Mutex
+	reg           prometheus.Registerer
+	clientOptions ClientOptions
+	filers        map[string]*registeredFiler
+	closed        bool
+
+	// reconcileMu serializes Reconcile, which creates the clients without holding the lock.
+	reconcileMu sync.Mutex
+}
+
+type registeredFiler struct {
+	config     NetappFiler
+	client     NetappFilerClient
+	collector  NetappCollector
+	registerer prometheus.Registerer
+	cancel     context.CancelFunc
+}
+
+// NewFilerRegistry returns a registry registering the filers with reg. Their clients are created with
+// clientOptions.
+func NewFilerRegistry(reg prometheus.Registerer, clientOptions ClientOptions) *FilerRegistry {
+	return &FilerRegistry{
+		reg:           reg,
+		clientOptions: clientOptions,
+		filers:        make(map[string]*registeredFiler),
+	}
+}
+
+// Reconcile registers new filers and unregisters removed ones. Filers whose config has changed, e.g. their
+// credentials, are registered anew with a new client. Unchanged filers keep their client, collectors and
+// data, so clients are only created for new and changed filers. If the client of a filer cannot be created,
+// its current registration is kept and an error is returned after the other filers have been reconciled.
+func (r *FilerRegistry) Reconcile(filers []NetappFiler) error {
+	r.reconcileMu.Lock()
+	defer r.reconcileMu.Unlock()
+
+	// the clients are created without holding the lock, since reading the credentials may take a while
+	var changed []NetappFiler
+	seen := make(map[string]bool)
+	r.Lock()
+	for _, f := range filers {
+		if seen[f.Name] {
+			logger.Warnf("Duplicate filer %s ignored", f.Name)
+			continue
+		}
+		seen[f.Name] = true
+		if old, ok := r.filers[f.Name]; ok && reflect.DeepEqual(old.config, f) {
+			continue
+		}
+		changed = append(changed, f)
+	}
+	r.Unlock()
+
+	var errs []string
+	clients := make(map[string]NetappFilerClient)
+	for _, f := range changed {
+		client, err := NewNetappClient(f, r.clientOptions)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		clients[f.Name] = client
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.closed {
+		return nil
+	}
+	for _, f := range changed {
+		client, ok := clients[f.Name]
+		if !ok {
+			continue
+		}
+		if old, ok := r.filers[f.Name]; ok {
+			logger.Printf("Filer %s changed", f.Name)
+			r.unregister(old)
+		}
+		r.register(f, client)
+	}
+	for name, old := range r.filers {
+		if !seen[name] {
+			r.unregister(old)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Len returns the number of registered filers.
+func (r *FilerRegistry) Len() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.filers)
+}
+
+// Shutdown cancels the fetches of all filers and waits for them to return, or until ctx is done. No filers
+// are registered afterwards.
+func (r *FilerRegistry) Shutdown(ctx context.Context) error {
+	r.Lock()
+	r.closed = true
+	var stopped []NetappCollector
+	for _, rf := range r.filers {
+		rf.cancel()
+		stopped = append(stopped, rf.collector)
+	}
+	r.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, c := range stopped {
+			c.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Lookup returns the collector of the registered filer with the given name or host.
+func (r *FilerRegistry) Lookup(target string) (NetappCollector, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if rf, ok := r.filers[target]; ok {
+		return rf.collector, true
+	}
+	for _, rf := range r.filers {
+		if rf.client.Host == target {
+			return rf.collector, true
+		}
+	}
+	return NetappCollector{}, false
+}
+
+func (r *FilerRegistry) register(config NetappFiler, f NetappFilerClient) {
+	username, _ := f.conn.credentials()
+	logger.Printf("Register filer: Name=%s Host=%s Username=%s AvailabilityZone=%s",
+		f.Name, f.Host, username, f.AvailabilityZone)
+	labels := prometheus.Labels{
+		"filer":             f.Name,
+		"availability_zone": f.AvailabilityZone,
+	}
+	rf := &registeredFiler{
+		config:     config,
+		client:     f,
+		collector:  NewNetappCollector(f),
+		registerer: prometheus.WrapRegistererWith(labels, r.reg),
+	}
+	if err := rf.registerer.Register(rf.collector); err != nil {
+		logger.Errorf("Register filer %s: %v", f.Name, err)
+		return
+	}
+
+	var ctx context.Context
+	ctx, rf.cancel = context.WithCancel(context.Background())
+	rf.collector.Start(ctx)
+	r.filers[f.Name] = rf
+}
+
+func (r *FilerRegistry) unregister(rf *registeredFiler) {
+	logger.Printf("Unregister filer: Name=%s", rf.client.Name)
+	rf.cancel()
+	rf.registerer.Unregister(rf.collector)
+	delete(r.filers, rf.client.Name)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/netapp-api-exporter/internal/fakezapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// registeredFilers returns the filers that have metrics in reg.
+func registeredFilers(t *testing.T, reg *prometheus.Registry) []string {
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	var res []string
+	for _, f := range families {
+		if f.GetName() != "netapp_filer_scrape_counter" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "filer" {
+					res = append(res, l.GetValue())
+				}
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestFilerRegistryReconcile(t *testing.T) {
+	s := fakezapi.NewServer()
+	defer s.Close()
+
+	filer := func(name string) NetappFiler {
+		f := fakeFilerConfig(s, "aggregate")
+		f.Name = name
+		return f
+	}
+
+	reg := prometheus.NewRegistry()
+	r := NewFilerRegistry(reg, ClientOptions{})
+	defer r.Shutdown(context.Background())
+
+	// add
+	assert.NoError(t, r.Reconcile([]NetappFiler{filer("a"), filer("b")}))
+	assert.Equal(t, []string{"a", "b"}, registeredFilers(t, reg))
+	a := r.filers["a"]
+
+	// unchanged filers keep their client and collector
+	assert.NoError(t, r.Reconcile([]NetappFiler{filer("a"), filer("b")}))
+	assert.True(t, a == r.filers["a"])
+	assert.True(t, a.client.conn == r.filers["a"].client.conn)
+
+	// changed filers are registered anew, without duplicate registration
+	changed := filer("a")
+	changed.AvailabilityZone = "az1"
+	assert.NoError(t, r.Reconcile([]NetappFiler{changed, filer("b")}))
+	assert.False(t, a == r.filers["a"])
+	assert.Equal(t, "az1", r.filers["a"].client.AvailabilityZone)
+	assert.Equal(t, []string{"a", "b"}, registeredFilers(t, reg))
+
+	// removed filers are unregistered
+	assert.NoError(t, r.Reconcile([]NetappFiler{changed}))
+	assert.Equal(t, []string{"a"}, registeredFilers(t, reg))
+
+	// a filer whose client cannot be created keeps its registration
+	broken := changed
+	broken.Backend = "unknown"
+	assert.Error(t, r.Reconcile([]NetappFiler{broken, filer("c")}))
+	assert.Equal(t, "az1", r.filers["a"].client.AvailabilityZone)
+	assert.Equal(t, []string{"a", "c"}, registeredFilers(t, reg))
+}